@@ -38,20 +38,26 @@ func DefaultEtcHosts() (string, error) {
 	return "", errors.New(runtime.GOOS + " is not yet supported")
 }
 
-func CopyFileP(fs afero.Fs, path string, newPath string) int64 {
-	r, err := CopyFile(fs, path, newPath)
+func CopyFileP(fs afero.Fs, path string, newPath string, options ...Option) int64 {
+	r, err := CopyFile(fs, path, newPath, options...)
 	if err != nil {
 		panic(err)
 	}
 	return r
 }
 
-func CopyFile(fs afero.Fs, path string, newPath string) (int64, error) {
+// CopyFile copies the file. The options.Progress, if any, observes the progress of copying
+func CopyFile(fs afero.Fs, path string, newPath string, options ...Option) (int64, error) {
 	err := EnsureFileExists(fs, path)
 	if err != nil {
 		return 0, err
 	}
 
+	fi, err := Stat(fs, path, true)
+	if err != nil {
+		return 0, err
+	}
+
 	src, err := fs.Open(path)
 	if err != nil {
 		return 0, errors.Wrapf(err, "read file %s", path)
@@ -64,7 +70,7 @@ func CopyFile(fs afero.Fs, path string, newPath string) (int64, error) {
 	}
 	defer dst.Close()
 
-	nBytes, err := io.Copy(dst, src)
+	nBytes, err := io.Copy(dst, newProgressReader(src, fi.Size(), 0, NewOptions(options...).Progress))
 	if err != nil {
 		return 0, errors.Wrapf(err, "copy file %s to %s", path, newPath)
 	}
@@ -1,7 +1,10 @@
 package ufs
 
 import (
+	"context"
+	"io"
 	"net/url"
+	"os"
 	"path/filepath"
 	"time"
 
@@ -16,19 +19,23 @@ type AferoFileT struct {
 	name        string
 	credentials Credentials
 	timeout     time.Duration
+	options     Options
+
+	// keeps the file once the downloaded blob is closed, see unverified()
+	keep bool
 }
 
 type AferoFile = *AferoFileT
 
-func NewAferoFileP(afs afero.Fs, apath string, credentials Credentials, timeout time.Duration) AferoFile {
-	r, err := NewAferoFile(afs, apath, credentials, timeout)
+func NewAferoFileP(afs afero.Fs, apath string, credentials Credentials, timeout time.Duration, options ...Option) AferoFile {
+	r, err := NewAferoFile(afs, apath, credentials, timeout, options...)
 	if err != nil {
 		panic(err)
 	}
 	return r
 }
 
-func NewAferoFile(afs afero.Fs, apath string, credentials Credentials, timeout time.Duration) (AferoFile, error) {
+func NewAferoFile(afs afero.Fs, apath string, credentials Credentials, timeout time.Duration, options ...Option) (AferoFile, error) {
 	var rawPath, rawUrl string
 	if IsFileProtocol(apath) {
 		rawPath = apath[len(FILE):]
@@ -50,6 +57,7 @@ func NewAferoFile(afs afero.Fs, apath string, credentials Credentials, timeout t
 		rawPath:     rawPath,
 		credentials: credentials,
 		timeout:     timeout,
+		options:     NewOptions(options...),
 	}, nil
 }
 
@@ -88,22 +96,237 @@ func (me AferoFile) Timeout() time.Duration {
 	return me.timeout
 }
 
+func (me AferoFile) Options() Options {
+	return me.options
+}
+
 func (me AferoFile) DownloadP() Content {
+	r, err := me.Download()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (me AferoFile) Download() (Content, error) {
+	checksum, err := checksumOf(context.Background(), me, me.readSibling)
+	if err != nil {
+		return nil, err
+	}
+
+	aferoBlob := NewAferoBlob(me.afs, me.rawPath)
+	// the file is kept if read by the verification into memory
+	aferoBlob.keep = me.keep || len(me.options.TrustedKeys) > 0
+
+	var blob io.ReadCloser = aferoBlob
+	blob = withChecksum(blob, me.Url(), checksum)
+
+	if observer := me.options.Progress; observer != nil {
+		total := int64(-1)
+		if fi, err := me.afs.Stat(me.rawPath); err == nil {
+			total = fi.Size()
+		}
+		blob = withProgress(blob, total, 0, observer)
+	}
+
+	blob, err = verifySignature(context.Background(), me, me.readSibling, blob)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ContentT{
 		Name: me.Name(),
 		Path: me.rawPath,
-		Blob: NewAferoBlob(me.afs, me.rawPath),
+		Blob: blob,
+	}, nil
+}
+
+func (me AferoFile) DownloadContextP(ctx context.Context) Content {
+	r, err := me.DownloadContext(ctx)
+	if err != nil {
+		panic(err)
 	}
+	return r
 }
 
-func (me AferoFile) Download() (Content, error) {
-	return me.DownloadP(), nil
+func (me AferoFile) DownloadContext(ctx context.Context) (Content, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return me.Download()
+}
+
+// readSibling reads the file of the name in the same directory
+func (me AferoFile) readSibling(ctx context.Context, name string) (string, []byte, error) {
+	p := filepath.Join(me.Dir(), name)
+	r, err := ReadBytes(me.afs, p)
+	return FILE + p, r, err
+}
+
+// unverified returns the same file, but the content is read as-is without the verification, and the
+// file is kept once the downloaded blob is closed
+func (me AferoFile) unverified() (File, error) {
+	r, err := NewAferoFile(me.afs, me.rawPath, me.credentials, me.timeout, me.options.unverifiedOption())
+	if err != nil {
+		return nil, err
+	}
+	r.keep = true
+	return r, nil
+}
+
+func (me AferoFile) UploadP(reader io.Reader) int64 {
+	r, err := me.Upload(reader)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (me AferoFile) Upload(reader io.Reader) (int64, error) {
+	w, err := me.Create()
+	if err != nil {
+		return 0, err
+	}
+
+	r, err := io.Copy(w, newProgressReader(reader, sizeOf(reader), 0, me.options.Progress))
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return r, errors.Wrapf(err, "write file: %s", me.rawPath)
+	}
+	return r, nil
+}
+
+func (me AferoFile) CreateP() io.WriteCloser {
+	r, err := me.Create()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (me AferoFile) Create() (io.WriteCloser, error) {
+	r, err := me.afs.OpenFile(me.rawPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create file: %s", me.rawPath)
+	}
+	return r, nil
+}
+
+func (me AferoFile) StatP() os.FileInfo {
+	r, err := me.Stat()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (me AferoFile) Stat() (os.FileInfo, error) {
+	return me.afs.Stat(me.rawPath)
+}
+
+func (me AferoFile) ExistsP() bool {
+	r, err := me.Exists()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (me AferoFile) Exists() (bool, error) {
+	fi, err := Stat(me.afs, me.rawPath, false)
+	if err != nil {
+		return false, err
+	}
+	return fi != nil, nil
+}
+
+func (me AferoFile) ListP() []os.FileInfo {
+	r, err := me.List()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (me AferoFile) List() ([]os.FileInfo, error) {
+	r, err := afero.ReadDir(me.afs, me.rawPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read directory: %s", me.rawPath)
+	}
+	return r, nil
+}
+
+func (me AferoFile) RemoveP() {
+	if err := me.Remove(); err != nil {
+		panic(err)
+	}
+}
+
+// Remove deletes the file, or the directory if it is empty. It is not an error if the file is not found.
+func (me AferoFile) Remove() error {
+	if err := me.afs.Remove(me.rawPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "delete file: %s", me.rawPath)
+	}
+	return nil
+}
+
+func (me AferoFile) MkdirP() {
+	if err := me.Mkdir(); err != nil {
+		panic(err)
+	}
+}
+
+// Mkdir creates the directory, whose parent must exist
+func (me AferoFile) Mkdir() error {
+	if err := me.afs.Mkdir(me.rawPath, os.ModePerm); err != nil {
+		return errors.Wrapf(err, "mkdir: %s", me.rawPath)
+	}
+	return nil
+}
+
+func (me AferoFile) OpenRandomP() RandomReader {
+	r, err := me.OpenRandom()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (me AferoFile) OpenRandom() (RandomReader, error) {
+	f, err := me.afs.Open(me.rawPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open file: %s", me.rawPath)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "stat file: %s", me.rawPath)
+	}
+	return &aferoRandomReaderT{File: f, size: fi.Size()}, nil
+}
+
+type aferoRandomReaderT struct {
+	afero.File
+	size int64
+}
+
+func (me *aferoRandomReaderT) Size() int64 {
+	return me.size
 }
 
 type AferoBlobT struct {
 	path string
 	afs  afero.Fs
 	file afero.File
+
+	// closes the file without deleting it
+	keep bool
 }
 
 type AferoBlob = *AferoBlobT
@@ -138,9 +361,11 @@ func (me AferoBlob) Close() error {
 		if err != nil {
 			return err
 		}
-		err = me.afs.Remove(me.path)
-		if err != nil {
-			return err
+		if !me.keep {
+			err = me.afs.Remove(me.path)
+			if err != nil {
+				return err
+			}
 		}
 		me.file = nil
 	}
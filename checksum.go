@@ -0,0 +1,217 @@
+package ufs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumSHA512 = "sha512"
+	ChecksumMD5    = "md5"
+)
+
+// ChecksumT is the expected digest of the downloaded content
+type ChecksumT struct {
+	// ChecksumSHA256, ChecksumSHA512 or ChecksumMD5
+	Algorithm string
+	// hex encoded
+	Digest string
+}
+
+type Checksum = *ChecksumT
+
+// ChecksumMismatchError is the error of the downloaded content which doesn't match the expected digest
+type ChecksumMismatchError struct {
+	Url       string
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (me *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch of %s: expected %s, actual %s", me.Algorithm, me.Url, me.Expected, me.Actual)
+}
+
+// isChecksumMismatch tells if the error is caused by ChecksumMismatchError
+func isChecksumMismatch(err error) bool {
+	var mismatchErr *ChecksumMismatchError
+	return errors.As(err, &mismatchErr)
+}
+
+func newChecksumHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumSHA512:
+		return sha512.New(), nil
+	case ChecksumMD5:
+		return md5.New(), nil
+	}
+	return nil, errors.Errorf("unsupported checksum algorithm: %s", algorithm)
+}
+
+// checksumAlgorithmOf guesses the algorithm by the length of the hex digest
+func checksumAlgorithmOf(digest string) string {
+	switch len(digest) {
+	case md5.Size * 2:
+		return ChecksumMD5
+	case sha256.Size * 2:
+		return ChecksumSHA256
+	case sha512.Size * 2:
+		return ChecksumSHA512
+	}
+	return ""
+}
+
+func (me Checksum) validate() error {
+	h, err := newChecksumHash(me.Algorithm)
+	if err != nil {
+		return err
+	}
+	if d, err := hex.DecodeString(me.Digest); err != nil || len(d) != h.Size() {
+		return errors.Errorf("invalid %s checksum: %s", me.Algorithm, me.Digest)
+	}
+	return nil
+}
+
+// Verify verifies the content against the digest
+func (me Checksum) Verify(url string, content []byte) error {
+	r := me.newReader(url, bytes.NewReader(content))
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+// newReader wraps the reader to verify the digest once the reader reaches EOF, when
+// ChecksumMismatchError is returned instead of io.EOF if not matched
+func (me Checksum) newReader(url string, reader io.Reader) io.Reader {
+	h, err := newChecksumHash(me.Algorithm)
+	if err == nil {
+		err = me.validate()
+	}
+	return &checksumReaderT{reader: reader, hash: h, url: url, checksum: me, err: err}
+}
+
+// checksumReaderT digests the content while being read
+type checksumReaderT struct {
+	reader   io.Reader
+	hash     hash.Hash
+	url      string
+	checksum Checksum
+	err      error
+}
+
+type checksumReader = *checksumReaderT
+
+func (me checksumReader) Read(p []byte) (int, error) {
+	if me.err != nil {
+		return 0, me.err
+	}
+
+	n, err := me.reader.Read(p)
+	me.hash.Write(p[:n])
+
+	if err == io.EOF {
+		actual := hex.EncodeToString(me.hash.Sum(nil))
+		if !strings.EqualFold(actual, me.checksum.Digest) {
+			me.err = &ChecksumMismatchError{
+				Url:       me.url,
+				Algorithm: strings.ToLower(me.checksum.Algorithm),
+				Expected:  strings.ToLower(me.checksum.Digest),
+				Actual:    actual,
+			}
+			return n, me.err
+		}
+	}
+	return n, err
+}
+
+// withChecksum is the io.ReadCloser version of Checksum.newReader, returns rc as-is if checksum is nil
+func withChecksum(rc io.ReadCloser, url string, checksum Checksum) io.ReadCloser {
+	if checksum == nil {
+		return rc
+	}
+	return newReadCloser(checksum.newReader(url, rc), rc.Close)
+}
+
+// checksumFragmentRegexp matches the url fragment of the expected digest, i.e, "#sha256=<hex>"
+var checksumFragmentRegexp = regexp.MustCompile(`(?i)#(sha256|sha512|md5)=([0-9a-f]+)$`)
+
+// splitChecksumFragment strips the "#sha256=<hex>" fragment off the url, and returns the checksum of it
+func splitChecksumFragment(url string) (string, Checksum) {
+	m := checksumFragmentRegexp.FindStringSubmatchIndex(url)
+	if m == nil {
+		return url, nil
+	}
+	return url[:m[0]], &ChecksumT{Algorithm: strings.ToLower(url[m[2]:m[3]]), Digest: url[m[4]:m[5]]}
+}
+
+// readSiblingFunc reads the file of the name in the same directory, returns its url and content
+type readSiblingFunc func(ctx context.Context, name string) (string, []byte, error)
+
+// checksumOf returns the expected checksum of the file, either specified by Options.Checksum or
+// looked up in Options.ChecksumsFile which is read by readSibling; nil if neither
+func checksumOf(ctx context.Context, f File, readSibling readSiblingFunc) (Checksum, error) {
+	options := f.Options()
+	if options.Checksum != nil {
+		return options.Checksum, nil
+	}
+	if options.ChecksumsFile == "" {
+		return nil, nil
+	}
+
+	sumsUrl, text, err := readSibling(ctx, options.ChecksumsFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read checksums file")
+	}
+
+	r := lookupChecksums(text, f.Name())
+	if r == nil {
+		return nil, errors.Errorf("no checksum of %s in %s", f.Name(), sumsUrl)
+	}
+	return r, nil
+}
+
+// bsdChecksumLineRegexp matches the BSD style line, i.e, "SHA256 (a.txt) = <hex>"
+var bsdChecksumLineRegexp = regexp.MustCompile(`^(SHA256|SHA512|MD5) \((.+)\) = ([0-9a-fA-F]+)$`)
+
+// lookupChecksums finds the checksum of the file name in the checksums file, which is either the GNU
+// coreutils format, i.e, "<hex>  a.txt" or "<hex> *a.txt", or the BSD format, i.e, "SHA256 (a.txt) = <hex>"
+func lookupChecksums(text []byte, name string) Checksum {
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var algorithm, digest, fileName string
+		if m := bsdChecksumLineRegexp.FindStringSubmatch(line); m != nil {
+			algorithm, fileName, digest = strings.ToLower(m[1]), m[2], m[3]
+		} else {
+			var found bool
+			if digest, fileName, found = strings.Cut(line, " "); !found {
+				continue
+			}
+			fileName = strings.TrimPrefix(strings.TrimLeft(fileName, " "), "*")
+			algorithm = checksumAlgorithmOf(digest)
+		}
+
+		if algorithm != "" && strings.TrimPrefix(fileName, "./") == name {
+			return &ChecksumT{Algorithm: algorithm, Digest: digest}
+		}
+	}
+	return nil
+}
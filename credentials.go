@@ -0,0 +1,86 @@
+package ufs
+
+import (
+	"crypto/tls"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// TLSMode is the FTPS connection strategy, see TLSExplicit and TLSImplicit
+type TLSMode = models.TLSMode
+
+const (
+	// TLSExplicit runs "AUTH TLS" on the plain connection before the login, the default
+	TLSExplicit = models.TLSExplicit
+	// TLSImplicit starts TLS right away, usually on port 990
+	TLSImplicit = models.TLSImplicit
+)
+
+// HostKeyPolicy tells how the sftp host key is verified, if Credentials.HostKeyFingerprint is not pinned
+type HostKeyPolicy string
+
+const (
+	// HostKeyStrict accepts only the host keys in the known_hosts file, the default
+	HostKeyStrict HostKeyPolicy = ""
+	// HostKeyTrustOnFirstUse accepts the unknown host, and appends its key to the known_hosts file;
+	// the known host presenting a different key is still rejected
+	HostKeyTrustOnFirstUse HostKeyPolicy = "tofu"
+	// HostKeyInsecure accepts any host key, only for testing
+	HostKeyInsecure HostKeyPolicy = "insecure"
+)
+
+// CredentialsT authorizes on the remote server
+type CredentialsT struct {
+	// username
+	User string
+	// password, also answers the keyboard-interactive prompts of sftp unless KeyboardInteractive is specified
+	Password string
+
+	// the session token of the temporary S3 credentials, S3Config.SessionToken takes precedence
+	SessionToken string
+
+	// sftp private key in PEM or OpenSSH format: RSA, ECDSA or ED25519
+	PrivateKey string
+	// sftp private key passphrase
+	PrivateKeyPassphrase string
+	// sftp private key file, i.e, "~/.ssh/id_ed25519", used if PrivateKey is empty
+	PrivateKeyFile string
+	// Deprecated: use PrivateKey, which is not limited to RSA
+	RsaPrivateKey string
+	// Deprecated: use PrivateKeyPassphrase
+	RsaPrivateKeyPassphrase string
+	// authenticates sftp with the keys of the ssh-agent listening on SSH_AUTH_SOCK
+	SshAgent bool
+	// answers the keyboard-interactive challenges of sftp, i.e, for the one-time password
+	KeyboardInteractive ssh.KeyboardInteractiveChallenge
+
+	// how the sftp host key is verified, HostKeyStrict by default
+	HostKeyPolicy HostKeyPolicy
+	// the known_hosts file, "~/.ssh/known_hosts" by default
+	KnownHostsFile string
+	// the pinned sftp host key fingerprint, in "SHA256:..." or the legacy MD5 "xx:xx:..." format as
+	// ssh-keygen -l prints. If specified, it is the only accepted host key and known_hosts is not used.
+	HostKeyFingerprint string
+
+	// ftps TLS config
+	TLSConfig *tls.Config
+	TLSMode   TLSMode
+}
+
+type Credentials = *CredentialsT
+
+// model converts to the credentials of go-universal-network-adapter
+func (me Credentials) model() *models.Credentials {
+	if me == nil {
+		return &models.Credentials{}
+	}
+	return &models.Credentials{
+		User:                    me.User,
+		Password:                me.Password,
+		RsaPrivateKey:           me.RsaPrivateKey,
+		RsaPrivateKeyPassphrase: me.RsaPrivateKeyPassphrase,
+		TLSConfig:               me.TLSConfig,
+		TLSMode:                 me.TLSMode,
+	}
+}
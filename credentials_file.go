@@ -0,0 +1,269 @@
+package ufs
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/scrypt"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultCredentialsFile is the encrypted credentials file if $UFS_CREDENTIALS_FILE is not set
+	DefaultCredentialsFile = "~/.config/ufs/credentials"
+
+	// CredentialsFileEnv specifies the encrypted credentials file
+	CredentialsFileEnv = "UFS_CREDENTIALS_FILE"
+	// CredentialsPassphraseEnv specifies the passphrase of the encrypted credentials file
+	CredentialsPassphraseEnv = "UFS_CREDENTIALS_PASSPHRASE"
+)
+
+// the encrypted credentials file is the magic, the scrypt salt, the AES-GCM nonce, then the sealed yaml
+var credentialsFileMagic = []byte("UFSCRED1")
+
+const (
+	credentialsSaltSize = 16
+	credentialsScryptN  = 1 << 15
+	credentialsScryptR  = 8
+	credentialsScryptP  = 1
+)
+
+// CredentialsEntryT is an entry of the encrypted credentials file
+type CredentialsEntryT struct {
+	// the url prefix which the credentials apply to, i.e, "sftp://example.com" for the whole host, or
+	// "https://example.com/api/" for the paths under /api/. The most specific entry wins.
+	Url string `yaml:"url"`
+
+	User                 string `yaml:"user,omitempty"`
+	Password             string `yaml:"password,omitempty"`
+	PrivateKey           string `yaml:"privateKey,omitempty"`
+	PrivateKeyPassphrase string `yaml:"privateKeyPassphrase,omitempty"`
+	PrivateKeyFile       string `yaml:"privateKeyFile,omitempty"`
+	SessionToken         string `yaml:"sessionToken,omitempty"`
+	HostKeyFingerprint   string `yaml:"hostKeyFingerprint,omitempty"`
+}
+
+type CredentialsEntry = *CredentialsEntryT
+
+func (me CredentialsEntry) credentials() Credentials {
+	return &CredentialsT{
+		User:                 me.User,
+		Password:             me.Password,
+		PrivateKey:           me.PrivateKey,
+		PrivateKeyPassphrase: me.PrivateKeyPassphrase,
+		PrivateKeyFile:       me.PrivateKeyFile,
+		SessionToken:         me.SessionToken,
+		HostKeyFingerprint:   me.HostKeyFingerprint,
+	}
+}
+
+// match returns the length of the matched path prefix, -1 if not matched
+func (me CredentialsEntry) match(u *url.URL) int {
+	prefix, err := url.Parse(me.Url)
+	if err != nil {
+		return -1
+	}
+	if prefix.Scheme != "" && !strings.EqualFold(prefix.Scheme, u.Scheme) {
+		return -1
+	}
+	if !strings.EqualFold(prefix.Hostname(), u.Hostname()) {
+		return -1
+	}
+	if prefix.Port() != "" && prefix.Port() != u.Port() {
+		return -1
+	}
+
+	dir := strings.TrimSuffix(prefix.Path, "/")
+	if dir != "" && u.Path != dir && !strings.HasPrefix(u.Path, dir+"/") {
+		return -1
+	}
+	return len(dir)
+}
+
+func WriteCredentialsFileP(fs afero.Fs, path string, passphrase string, entries []CredentialsEntryT) {
+	if err := WriteCredentialsFile(fs, path, passphrase, entries); err != nil {
+		panic(err)
+	}
+}
+
+// WriteCredentialsFile writes the entries into the credentials file, encrypted by AES-256-GCM with
+// the key derived from the passphrase by scrypt
+func WriteCredentialsFile(fs afero.Fs, path string, passphrase string, entries []CredentialsEntryT) error {
+	if passphrase == "" {
+		return errors.New("passphrase of credentials file is empty")
+	}
+
+	plain, err := yaml.Marshal(entries)
+	if err != nil {
+		return errors.Wrap(err, "marshal credentials")
+	}
+
+	salt := make([]byte, credentialsSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.Wrap(err, "generate salt")
+	}
+	aead, err := credentialsCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "generate nonce")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(credentialsFileMagic)
+	buf.Write(salt)
+	buf.Write(nonce)
+	buf.Write(aead.Seal(nil, nonce, plain, credentialsFileMagic))
+
+	if err := fs.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Wrapf(err, "create directory of credentials file: %s", path)
+	}
+	if err := afero.WriteFile(fs, path, buf.Bytes(), 0o600); err != nil {
+		return errors.Wrapf(err, "write credentials file: %s", path)
+	}
+	return nil
+}
+
+func ReadCredentialsFileP(fs afero.Fs, path string, passphrase string) []CredentialsEntryT {
+	r, err := ReadCredentialsFile(fs, path, passphrase)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// ReadCredentialsFile reads and decrypts the entries of the credentials file
+func ReadCredentialsFile(fs afero.Fs, path string, passphrase string) ([]CredentialsEntryT, error) {
+	content, err := ReadBytes(fs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := len(credentialsFileMagic) + credentialsSaltSize
+	if len(content) < headerSize || !bytes.Equal(content[:len(credentialsFileMagic)], credentialsFileMagic) {
+		return nil, errors.Errorf("not a credentials file: %s", path)
+	}
+	salt := content[len(credentialsFileMagic):headerSize]
+
+	aead, err := credentialsCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) < headerSize+aead.NonceSize() {
+		return nil, errors.Errorf("truncated credentials file: %s", path)
+	}
+	nonce := content[headerSize : headerSize+aead.NonceSize()]
+
+	plain, err := aead.Open(nil, nonce, content[headerSize+aead.NonceSize():], credentialsFileMagic)
+	if err != nil {
+		return nil, errors.Errorf("decrypt credentials file %s: wrong passphrase or corrupted file", path)
+	}
+
+	var r []CredentialsEntryT
+	if err := yaml.Unmarshal(plain, &r); err != nil {
+		return nil, errors.Wrapf(err, "parse credentials file: %s", path)
+	}
+	return r, nil
+}
+
+func credentialsCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, credentialsScryptN, credentialsScryptR, credentialsScryptP, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "derive key of credentials file")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// credentialsFileProviderT caches the decrypted entries until the file is modified
+type credentialsFileProviderT struct {
+	fs         afero.Fs
+	path       string
+	passphrase string
+
+	mutex    sync.Mutex
+	cacheKey string
+	entries  []CredentialsEntryT
+}
+
+// CredentialsFileProvider finds the most specific entry of the encrypted credentials file, see
+// WriteCredentialsFile(). The path is $UFS_CREDENTIALS_FILE or DefaultCredentialsFile if empty, and
+// the passphrase is $UFS_CREDENTIALS_PASSPHRASE if empty. Without passphrase or the file, it finds nothing.
+func CredentialsFileProvider(fs afero.Fs, path string, passphrase string) CredentialsProvider {
+	return &credentialsFileProviderT{fs: fs, path: path, passphrase: passphrase}
+}
+
+func (me *credentialsFileProviderT) Credentials(u *url.URL) (Credentials, error) {
+	entries, err := me.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var r CredentialsEntry
+	longest := -1
+	for i := range entries {
+		if n := entries[i].match(u); n > longest {
+			r, longest = &entries[i], n
+		}
+	}
+	if r == nil {
+		return nil, nil
+	}
+	return r.credentials(), nil
+}
+
+func (me *credentialsFileProviderT) load() ([]CredentialsEntryT, error) {
+	passphrase := me.passphrase
+	if passphrase == "" {
+		if passphrase = os.Getenv(CredentialsPassphraseEnv); passphrase == "" {
+			return nil, nil
+		}
+	}
+
+	path := me.path
+	if path == "" {
+		if path = os.Getenv(CredentialsFileEnv); path == "" {
+			path = DefaultCredentialsFile
+		}
+	}
+	path, err := ExpandHomePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := me.fs.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "stat credentials file: %s", path)
+	}
+
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	cacheKey := strings.Join([]string{path, passphrase, fi.ModTime().String(), strconv.FormatInt(fi.Size(), 10)}, "\x00")
+	if cacheKey != me.cacheKey {
+		entries, err := ReadCredentialsFile(me.fs, path, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		me.cacheKey, me.entries = cacheKey, entries
+	}
+	return me.entries, nil
+}
@@ -0,0 +1,272 @@
+package ufs
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/goodsru/go-universal-network-adapter/services"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// CredentialsProvider resolves the credentials of the remote file, when the credentials are not
+// specified to NewFile(), DownloadBytes() and so on, see WithCredentialsProvider()
+type CredentialsProvider interface {
+	// Credentials returns the credentials for the url, nil if not found
+	Credentials(u *url.URL) (Credentials, error)
+}
+
+// WithCredentialsProvider specifies the CredentialsProvider, instead of DefaultCredentialsProvider().
+// CredentialsProviderChain() without any provider disables the resolving.
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(options Options) {
+		options.CredentialsProvider = provider
+	}
+}
+
+// CredentialsProviderFunc adapts the function as CredentialsProvider
+type CredentialsProviderFunc func(u *url.URL) (Credentials, error)
+
+func (me CredentialsProviderFunc) Credentials(u *url.URL) (Credentials, error) {
+	return me(u)
+}
+
+// CredentialsProviderChain returns the credentials of the first provider which finds them
+func CredentialsProviderChain(providers ...CredentialsProvider) CredentialsProvider {
+	return CredentialsProviderFunc(func(u *url.URL) (Credentials, error) {
+		for _, provider := range providers {
+			r, err := provider.Credentials(u)
+			if err != nil || r != nil {
+				return r, err
+			}
+		}
+		return nil, nil
+	})
+}
+
+var _defaultCredentialsChain = CredentialsProviderChain(
+	EnvCredentialsProvider(),
+	NetrcCredentialsProvider(afero.NewOsFs(), ""),
+	CredentialsFileProvider(afero.NewOsFs(), "", ""),
+)
+
+var _defaultCredentialsProvider = CredentialsProviderFunc(func(u *url.URL) (Credentials, error) {
+	if strings.EqualFold(u.Scheme, services.S3) {
+		return nil, nil
+	}
+	return _defaultCredentialsChain.Credentials(u)
+})
+
+// DefaultCredentialsProvider consults, in order, the environment variables, ~/.netrc and the encrypted
+// credentials file. The s3 urls are left to the AWS SDK, which consults the AWS environment variables,
+// ~/.aws/credentials of S3Config.Profile, the SSO and the instance role, see WithS3().
+func DefaultCredentialsProvider() CredentialsProvider {
+	return _defaultCredentialsProvider
+}
+
+// resolveCredentials returns the credentials by the provider, empty credentials if not found
+func resolveCredentials(rawUrl string, provider CredentialsProvider) (Credentials, error) {
+	if provider == nil {
+		provider = DefaultCredentialsProvider()
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse url: %s", rawUrl)
+	}
+	if u.Host == "" {
+		return &CredentialsT{}, nil
+	}
+
+	r, err := provider.Credentials(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolve credentials of %s", u.Redacted())
+	}
+	if r == nil {
+		return &CredentialsT{}, nil
+	}
+	return r, nil
+}
+
+// EnvCredentialsProvider reads UFS_<HOST>_USER, UFS_<HOST>_PASSWORD and UFS_<HOST>_PRIVATE_KEY_FILE,
+// where <HOST> is the url host in upper case with the non-alphanumeric characters replaced by "_",
+// i.e, UFS_EXAMPLE_COM_2222_USER for example.com:2222. The variables with port are consulted first.
+func EnvCredentialsProvider() CredentialsProvider {
+	return CredentialsProviderFunc(func(u *url.URL) (Credentials, error) {
+		hosts := []string{u.Host}
+		if u.Port() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+
+		for _, host := range hosts {
+			prefix := "UFS_" + envName(host) + "_"
+			r := &CredentialsT{
+				User:           os.Getenv(prefix + "USER"),
+				Password:       os.Getenv(prefix + "PASSWORD"),
+				PrivateKeyFile: os.Getenv(prefix + "PRIVATE_KEY_FILE"),
+			}
+			if r.User != "" || r.Password != "" || r.PrivateKeyFile != "" {
+				return r, nil
+			}
+		}
+		return nil, nil
+	})
+}
+
+// envName converts to the environment variable name: upper case with the non-alphanumeric characters replaced by "_"
+func envName(s string) string {
+	return strings.Map(func(c rune) rune {
+		if c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			return unicode.ToUpper(c)
+		}
+		return '_'
+	}, s)
+}
+
+// netrcProviderT caches the parsed netrc file until the file is modified
+type netrcProviderT struct {
+	fs   afero.Fs
+	path string
+
+	mutex    sync.Mutex
+	cacheKey string
+	netrc    netrc
+}
+
+// NetrcCredentialsProvider reads the login and password of the url host from the netrc file, which
+// is $NETRC or ~/.netrc if the path is empty. The missing file finds nothing.
+func NetrcCredentialsProvider(fs afero.Fs, path string) CredentialsProvider {
+	return &netrcProviderT{fs: fs, path: path}
+}
+
+func (me *netrcProviderT) Credentials(u *url.URL) (Credentials, error) {
+	n, err := me.load()
+	if err != nil || n == nil {
+		return nil, err
+	}
+	return n.lookup(u.Hostname()), nil
+}
+
+func (me *netrcProviderT) load() (netrc, error) {
+	p := me.path
+	if p == "" {
+		if p = os.Getenv("NETRC"); p == "" {
+			p = "~/.netrc"
+		}
+	}
+	p, err := ExpandHomePath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := me.fs.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "stat netrc: %s", p)
+	}
+
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	cacheKey := strings.Join([]string{p, fi.ModTime().String(), strconv.FormatInt(fi.Size(), 10)}, "\x00")
+	if cacheKey != me.cacheKey {
+		content, err := afero.ReadFile(me.fs, p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read netrc: %s", p)
+		}
+		me.cacheKey, me.netrc = cacheKey, parseNetrc(content)
+	}
+	return me.netrc, nil
+}
+
+// netrcT is the parsed netrc file
+type netrcT struct {
+	// the first entry of each machine, by the lower case machine name
+	machines map[string]*CredentialsT
+	// the default entry, nil if not present
+	fallback *CredentialsT
+}
+
+type netrc = *netrcT
+
+// lookup returns the credentials of the machine, or of the default entry, nil if neither
+func (me netrc) lookup(machine string) Credentials {
+	c := me.machines[strings.ToLower(machine)]
+	if c == nil {
+		if c = me.fallback; c == nil {
+			return nil
+		}
+	}
+	// a copy, the cached one is shared
+	r := *c
+	return &r
+}
+
+// parseNetrc parses the machine entries and the default entry
+func parseNetrc(content []byte) netrc {
+	r := &netrcT{machines: map[string]*CredentialsT{}}
+	var current Credentials
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// the macro definition ends with an empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			next := func() string {
+				if i+1 < len(fields) {
+					i++
+					return fields[i]
+				}
+				return ""
+			}
+
+			switch fields[i] {
+			case "machine":
+				current = nil
+				if name := strings.ToLower(next()); r.machines[name] == nil {
+					current = &CredentialsT{}
+					r.machines[name] = current
+				}
+			case "default":
+				current = nil
+				if r.fallback == nil {
+					r.fallback = &CredentialsT{}
+					current = r.fallback
+				}
+			case "login":
+				if login := next(); current != nil {
+					current.User = login
+				}
+			case "password":
+				if password := next(); current != nil {
+					current.Password = password
+				}
+			case "account":
+				next()
+			case "macdef":
+				next()
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	return r
+}
@@ -0,0 +1,175 @@
+package ufs
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DavScheme is WebDAV over http
+	DavScheme = "dav"
+	// DavsScheme is WebDAV over https
+	DavsScheme = "davs"
+)
+
+// davProtocol is WebDAV (RFC 4918) on top of the http protocol: GET to download, PUT to upload,
+// PROPFIND to stat and list, MKCOL to create directory, and DELETE to remove
+type davProtocol struct {
+	httpProtocol
+}
+
+var _ DirMaker = (*davProtocol)(nil)
+
+const davPropfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/><D:getlastmodified/><D:getetag/></D:prop></D:propfind>`
+
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Status string  `xml:"DAV: status"`
+	Prop   davProp `xml:"DAV: prop"`
+}
+
+type davProp struct {
+	ResourceType struct {
+		Collection *struct{} `xml:"DAV: collection"`
+	} `xml:"DAV: resourcetype"`
+	ContentLength string `xml:"DAV: getcontentlength"`
+	LastModified  string `xml:"DAV: getlastmodified"`
+	ETag          string `xml:"DAV: getetag"`
+}
+
+// path returns the unescaped path of the href, without the trailing "/"
+func (me davResponse) path() string {
+	r := me.Href
+	if u, err := url.Parse(me.Href); err == nil {
+		r = u.Path
+	}
+	return strings.TrimSuffix(r, "/")
+}
+
+// fileInfo returns the file info with the properties of 200 propstat
+func (me davResponse) fileInfo() RemoteFileInfo {
+	r := &RemoteFileInfoT{Filename: path.Base("/" + me.path())}
+	for _, propstat := range me.Propstats {
+		if fields := strings.Fields(propstat.Status); len(fields) < 2 || fields[1] != "200" {
+			continue
+		}
+
+		prop := propstat.Prop
+		r.Directory = prop.ResourceType.Collection != nil
+		if prop.ContentLength != "" {
+			r.Length, _ = strconv.ParseInt(prop.ContentLength, 10, 64)
+		}
+		if prop.LastModified != "" {
+			r.Lastmod, _ = http.ParseTime(prop.LastModified)
+		}
+		r.ETag = prop.ETag
+	}
+	return r
+}
+
+// propfind sends PROPFIND request with the depth, 0 for the file itself, 1 for the directory entries too
+func (me *davProtocol) propfind(ctx context.Context, f RemoteFile, depth int) ([]davResponse, error) {
+	req, err := me.newRequest(ctx, f, "PROPFIND", strings.NewReader(davPropfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", strconv.Itoa(depth))
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := me.do(f, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var r davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, errors.Wrapf(err, "parse PROPFIND response of %s", f.Url())
+	}
+	if len(r.Responses) == 0 {
+		return nil, errors.Errorf("empty PROPFIND response of %s", f.Url())
+	}
+	return r.Responses, nil
+}
+
+// Stat sends PROPFIND request with depth 0
+func (me *davProtocol) Stat(ctx context.Context, f RemoteFile) (os.FileInfo, error) {
+	responses, err := me.propfind(ctx, f, 0)
+	if err != nil {
+		return nil, err
+	}
+	return responses[0].fileInfo(), nil
+}
+
+// List sends PROPFIND request with depth 1, the directory itself is excluded
+func (me *davProtocol) List(ctx context.Context, f RemoteFile) ([]os.FileInfo, error) {
+	responses, err := me.propfind(ctx, f, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	self := strings.TrimSuffix(f.URL().Path, "/")
+	r := []os.FileInfo{}
+	for _, resp := range responses {
+		if resp.path() != self {
+			r = append(r, resp.fileInfo())
+		}
+	}
+	return r, nil
+}
+
+// Remove sends DELETE request. WebDAV deletes the directory recursively, so the non-empty directory
+// is refused with syscall.ENOTEMPTY, same as the other protocols.
+func (me *davProtocol) Remove(ctx context.Context, f RemoteFile) error {
+	entries, err := me.List(ctx, f)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return &os.PathError{Op: "remove", Path: f.Url(), Err: syscall.ENOTEMPTY}
+	}
+	return me.httpProtocol.Remove(ctx, f)
+}
+
+// Mkdir sends MKCOL request
+func (me *davProtocol) Mkdir(ctx context.Context, f RemoteFile) error {
+	req, err := me.newRequest(ctx, f, "MKCOL", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := me.do(f, req)
+	if err != nil {
+		var statusErr *HttpStatusError
+		if errors.As(err, &statusErr) {
+			switch statusErr.StatusCode {
+			case http.StatusMethodNotAllowed:
+				// MKCOL on the existing resource
+				return errors.Wrap(os.ErrExist, err.Error())
+			case http.StatusConflict:
+				// the parent does not exist
+				return errors.Wrap(os.ErrNotExist, err.Error())
+			}
+		}
+		return err
+	}
+	return resp.Body.Close()
+}
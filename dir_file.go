@@ -0,0 +1,130 @@
+package ufs
+
+import (
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// dirFileT is the afero.File of a remote directory, which could only be listed
+type dirFileT struct {
+	name string
+	stat func() (os.FileInfo, error)
+	list func() ([]os.FileInfo, error)
+
+	loaded  bool
+	entries []os.FileInfo
+}
+
+type dirFile = *dirFileT
+
+var _ afero.File = (*dirFileT)(nil)
+
+// newDirFile creates a dirFile, the entries are listed once Readdir() is called
+func newDirFile(name string, stat func() (os.FileInfo, error), list func() ([]os.FileInfo, error)) dirFile {
+	return &dirFileT{name: name, stat: stat, list: list}
+}
+
+func (me dirFile) isDirError(op string) error {
+	return &os.PathError{Op: op, Path: me.name, Err: syscall.EISDIR}
+}
+
+func (me dirFile) Name() string {
+	return me.name
+}
+
+func (me dirFile) Close() error {
+	return nil
+}
+
+func (me dirFile) Sync() error {
+	return nil
+}
+
+func (me dirFile) Stat() (os.FileInfo, error) {
+	return me.stat()
+}
+
+// Readdir returns the next count entries, or all the remaining entries if count <= 0
+func (me dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !me.loaded {
+		entries, err := me.list()
+		if err != nil {
+			return nil, err
+		}
+		me.entries = entries
+		me.loaded = true
+	}
+
+	if count <= 0 {
+		r := me.entries
+		me.entries = nil
+		return r, nil
+	}
+
+	if len(me.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(me.entries) {
+		count = len(me.entries)
+	}
+	r := me.entries[:count]
+	me.entries = me.entries[count:]
+	return r, nil
+}
+
+func (me dirFile) Readdirnames(n int) ([]string, error) {
+	entries, err := me.Readdir(n)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]string, len(entries))
+	for i, entry := range entries {
+		r[i] = entry.Name()
+	}
+	return r, nil
+}
+
+func (me dirFile) Read(p []byte) (int, error) {
+	return 0, me.isDirError("read")
+}
+
+func (me dirFile) ReadAt(p []byte, off int64) (int, error) {
+	return 0, me.isDirError("read")
+}
+
+func (me dirFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, me.isDirError("seek")
+}
+
+func (me dirFile) Write(p []byte) (int, error) {
+	return 0, me.isDirError("write")
+}
+
+func (me dirFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, me.isDirError("write")
+}
+
+func (me dirFile) WriteString(s string) (int, error) {
+	return 0, me.isDirError("write")
+}
+
+func (me dirFile) Truncate(size int64) error {
+	return me.isDirError("truncate")
+}
+
+// newPathError makes the error a *os.PathError of the name, as afero.Fs implementations do. Returns nil if err is nil
+func newPathError(op string, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
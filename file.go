@@ -1,7 +1,11 @@
 package ufs
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -21,6 +25,8 @@ const (
 	FTPS  = services.FTPS + "://"
 	SFTP  = services.SFTP + "://"
 	S3    = services.S3 + "://"
+	DAV   = DavScheme + "://"
+	DAVS  = DavsScheme + "://"
 	FILE  = "file://"
 )
 
@@ -32,51 +38,83 @@ type File interface {
 	URL() *url.URL
 	Credentials() Credentials
 	Timeout() time.Duration
+	Options() Options
 	DownloadP() Content
 	Download() (Content, error)
+	DownloadContextP(ctx context.Context) Content
+	DownloadContext(ctx context.Context) (Content, error)
+	UploadP(reader io.Reader) int64
+	Upload(reader io.Reader) (int64, error)
+	CreateP() io.WriteCloser
+	Create() (io.WriteCloser, error)
+	StatP() os.FileInfo
+	Stat() (os.FileInfo, error)
+	ExistsP() bool
+	Exists() (bool, error)
+	ListP() []os.FileInfo
+	List() ([]os.FileInfo, error)
+	RemoveP()
+	Remove() error
+	MkdirP()
+	Mkdir() error
+	OpenRandomP() RandomReader
+	OpenRandom() (RandomReader, error)
 }
 
-type (
-	CredentialsT = models.Credentials
-	Credentials  = *CredentialsT
-)
-
 type (
 	ContentT = models.RemoteFileContent
 	Content  = *ContentT
 )
 
-func NewFileP(afs afero.Fs, url string, credentials Credentials, timeout time.Duration) File {
-	r, err := NewFile(afs, url, credentials, timeout)
+func NewFileP(afs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) File {
+	r, err := NewFile(afs, url, credentials, timeout, options...)
 	if err != nil {
 		panic(err)
 	}
 	return r
 }
 
-func NewFile(afs afero.Fs, url string, credentials Credentials, timeout time.Duration) (File, error) {
-	if IsRemote(url) {
-		return NewRemoteFile(url, credentials, timeout)
+// NewFile creates the remote file if the url scheme is registered, otherwise the afero file.
+// The url could be "<name>:path/to/file" of a named remote, see ResolveRemote(), and the url fragment
+// "#sha256=<hex>" (or sha512, md5) specifies the expected digest, see WithChecksum().
+func NewFile(afs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) (File, error) {
+	url, checksum := splitChecksumFragment(url)
+	if checksum != nil {
+		options = append([]Option{WithChecksum(checksum.Algorithm, checksum.Digest)}, options...)
+	}
+
+	url, credentials, timeout, options, err := expandRemote(url, credentials, timeout, options)
+	if err != nil {
+		return nil, err
 	}
-	return NewAferoFile(afs, url, credentials, timeout)
+
+	if hasRemoteScheme(url) {
+		return NewRemoteFile(url, credentials, timeout, options...)
+	}
+	return NewAferoFile(afs, url, credentials, timeout, options...)
 }
 
 func IsFileProtocol(url string) bool {
 	return strings.HasPrefix(strings.ToLower(url), FILE)
 }
 
+// IsRemote tells if the url scheme is registered by RegisterProtocol(), or the url is "<name>:path/to/file"
+// of a named remote found by ResolveRemote(). The local path with colon, i.e, "build:x/out.txt", is not remote.
 func IsRemote(url string) bool {
-	lc := strings.ToLower(url)
-
-	if strings.HasPrefix(lc, HTTP) ||
-		strings.HasPrefix(lc, HTTPS) ||
-		strings.HasPrefix(lc, FTP) ||
-		strings.HasPrefix(lc, FTPS) ||
-		strings.HasPrefix(lc, SFTP) ||
-		strings.HasPrefix(lc, S3) {
+	if hasRemoteScheme(url) {
 		return true
 	}
-	return false
+	remote, expanded, err := ResolveRemote(url)
+	return err == nil && remote != nil && hasRemoteScheme(expanded)
+}
+
+// hasRemoteScheme tells if the url scheme is registered by RegisterProtocol()
+func hasRemoteScheme(url string) bool {
+	posOfProtocolSep := strings.Index(url, "://")
+	if posOfProtocolSep <= 0 {
+		return false
+	}
+	return LookupProtocol(url[:posOfProtocolSep]) != nil
 }
 
 func WorkDir(url string, defaultDir string) string {
@@ -115,34 +153,85 @@ func ShortDescription(url string) string {
 	return protocol + r[:3] + "..." + r[lem-(8+1+5): /* 12345678.hosts */]
 }
 
-func DownloadBytesP(logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration) []byte {
-	r, err := DownloadBytes(logger, fallbackDir, fs, url, credentials, timeout)
+func ListURLP(fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) []os.FileInfo {
+	r, err := ListURL(fs, url, credentials, timeout, options...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// ListURL lists the directory of the url, which could be either remote or local
+func ListURL(fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) ([]os.FileInfo, error) {
+	f, err := NewFile(fs, url, credentials, timeout, options...)
+	if err != nil {
+		return nil, err
+	}
+	return f.List()
+}
+
+func DownloadBytesP(logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) []byte {
+	r, err := DownloadBytes(logger, fallbackDir, fs, url, credentials, timeout, options...)
 	if err != nil {
 		panic(err)
 	}
 	return r
 }
 
-func DownloadBytes(logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration) (result []byte, err error) {
-	result, err = downloadBytes(fs, url, credentials, timeout)
+func DownloadBytes(logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) ([]byte, error) {
+	return DownloadBytesContext(context.Background(), logger, fallbackDir, fs, url, credentials, timeout, options...)
+}
+
+func DownloadBytesContextP(ctx context.Context, logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) []byte {
+	r, err := DownloadBytesContext(ctx, logger, fallbackDir, fs, url, credentials, timeout, options...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
 
-	if len(fallbackDir) > 0 {
+// DownloadBytesContext is DownloadBytes which is aborted once the context is done.
+// The fallback file is not used if the download is aborted by the context, or the downloaded
+// content doesn't match the expected checksum or the signature. The fallback file is verified
+// against the checksum specified by option or url fragment, if any, and against the signature saved
+// beside it if Options.TrustedKeys is specified, so the fallback file of an unsigned download is not used.
+func DownloadBytesContext(ctx context.Context, logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) (result []byte, err error) {
+	// the retries are logged with the logger, unless another one is specified in options
+	options = append([]Option{WithLogger(logger)}, options...)
+	result, signature, err := downloadBytes(ctx, fs, url, credentials, timeout, options...)
+
+	if len(fallbackDir) > 0 && ctx.Err() == nil {
 		if err == nil {
 			if logger != nil {
 				logger.Info().Str("fallbackDir", fallbackDir).Str("url", url).Msg("save download files to fallback dir")
 			}
-			fallbackFilePath, fallbackErr := WriteFallbackFile(fallbackDir, fs, url, result)
+			// the content verified with the trusted keys is saved only together with its signature
+			fallbackFilePath := FallbackFilePath(fallbackDir, url)
+			var fallbackErr error
+			if len(NewOptions(options...).TrustedKeys) > 0 {
+				if signature == nil {
+					fallbackErr = &SignatureError{Url: url, Reason: "the signature is not kept"}
+				} else {
+					_, fallbackErr = WriteFallbackFile(fallbackDir, fs, url+SignatureSuffix, signature)
+				}
+			}
+			if fallbackErr == nil {
+				fallbackFilePath, fallbackErr = WriteFallbackFile(fallbackDir, fs, url, result)
+			}
 			if fallbackErr != nil {
 				if logger != nil {
 					logger.Warn().Err(fallbackErr).Str("url", url).Str("fallbackFilePath", fallbackFilePath).Msg("save fallback file failed")
 				}
 			}
 			return result, err
-		} else {
+		} else if !isChecksumMismatch(err) && !isSignatureError(err) {
 			if logger != nil {
 				logger.Warn().Err(err).Str("url", url).Msg("fallbacking due to failed to download the file")
 			}
 			fallbackFilePath, r, fallbackErr := ReadFallbackFile(fallbackDir, fs, url)
+			if fallbackErr == nil {
+				fallbackErr = verifyFallback(fallbackDir, fs, url, options, r)
+			}
 			if fallbackErr != nil {
 				if logger != nil {
 					logger.Warn().Err(fallbackErr).Str("url", url).Str("fallbackFilePath", fallbackFilePath).Msg("get fallbacked file failed too")
@@ -157,33 +246,81 @@ func DownloadBytes(logger comm.Logger, fallbackDir string, fs afero.Fs, url stri
 	return result, err
 }
 
-func downloadBytes(fs afero.Fs, url string, credentials Credentials, timeout time.Duration) ([]byte, error) {
-	f, err := NewFile(fs, url, credentials, timeout)
+// verifyFallback verifies the fallback content against the checksum specified by option or url fragment,
+// and against the signature saved by saveFallbackSignature() if Options.TrustedKeys is specified
+func verifyFallback(fallbackDir string, fs afero.Fs, url string, options []Option, content []byte) error {
+	opts := NewOptions(options...)
+	if keys := opts.TrustedKeys; len(keys) > 0 {
+		_, sig, err := ReadFallbackFile(fallbackDir, fs, url+SignatureSuffix)
+		if err != nil {
+			return &SignatureError{Url: url, Reason: "read the fallback signature", Err: err}
+		}
+		if sig == nil {
+			return &SignatureError{Url: url, Reason: "unsigned, no fallback signature"}
+		}
+		reason, err := verifyDetached(keys, bytes.NewReader(content), sig)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			return &SignatureError{Url: url, Reason: reason}
+		}
+	}
+
+	url, checksum := splitChecksumFragment(url)
+	if opts.Checksum != nil {
+		checksum = opts.Checksum
+	}
+	if checksum == nil {
+		return nil
+	}
+	return checksum.Verify(url, content)
+}
+
+// downloadBytes returns the content, and the signature which the content is verified against if any
+func downloadBytes(ctx context.Context, fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) ([]byte, []byte, error) {
+	f, err := NewFile(fs, url, credentials, timeout, options...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	c, err := f.Download()
+	c, err := f.DownloadContext(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	blob := c.Blob
 	defer blob.Close()
 
-	return comm.ReadBytes(blob)
+	r, err := comm.ReadBytes(newContextReader(ctx, blob))
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, signatureOf(blob), nil
+}
+
+func DownloadTextP(logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) string {
+	r, err := DownloadText(logger, fallbackDir, fs, url, credentials, timeout, options...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func DownloadText(logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) (string, error) {
+	return DownloadTextContext(context.Background(), logger, fallbackDir, fs, url, credentials, timeout, options...)
 }
 
-func DownloadTextP(logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration) string {
-	r, err := DownloadText(logger, fallbackDir, fs, url, credentials, timeout)
+func DownloadTextContextP(ctx context.Context, logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) string {
+	r, err := DownloadTextContext(ctx, logger, fallbackDir, fs, url, credentials, timeout, options...)
 	if err != nil {
 		panic(err)
 	}
 	return r
 }
 
-func DownloadText(logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration) (string, error) {
-	bytes, err := DownloadBytes(logger, fallbackDir, fs, url, credentials, timeout)
+func DownloadTextContext(ctx context.Context, logger comm.Logger, fallbackDir string, fs afero.Fs, url string, credentials Credentials, timeout time.Duration, options ...Option) (string, error) {
+	bytes, err := DownloadBytesContext(ctx, logger, fallbackDir, fs, url, credentials, timeout, options...)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,46 @@
+package ufs
+
+import (
+	"os"
+	"time"
+)
+
+// RemoteFileInfoT is the os.FileInfo of remote files
+type RemoteFileInfoT struct {
+	Filename string
+	// content length in bytes, -1 if unknown
+	Length    int64
+	Lastmod   time.Time
+	Directory bool
+	// entity tag of the content, if the protocol provides it (http, s3)
+	ETag string
+}
+
+type RemoteFileInfo = *RemoteFileInfoT
+
+func (me RemoteFileInfo) Name() string {
+	return me.Filename
+}
+
+func (me RemoteFileInfo) Size() int64 {
+	return me.Length
+}
+
+func (me RemoteFileInfo) Mode() os.FileMode {
+	if me.Directory {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+func (me RemoteFileInfo) ModTime() time.Time {
+	return me.Lastmod
+}
+
+func (me RemoteFileInfo) IsDir() bool {
+	return me.Directory
+}
+
+func (me RemoteFileInfo) Sys() any {
+	return nil
+}
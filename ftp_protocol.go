@@ -0,0 +1,297 @@
+package ufs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/secsy/goftp"
+)
+
+// ftpProtocol serves both ftp and ftps, see Credentials.TLSConfig and Credentials.TLSMode
+type ftpProtocol struct{}
+
+// ftpClientT closes the connections once the context is done, which interrupts any transfer in progress
+type ftpClientT struct {
+	*goftp.Client
+	stopWatch func() bool
+}
+
+type ftpClient = *ftpClientT
+
+func (me ftpClient) Close() error {
+	if !me.stopWatch() {
+		// already closed by the context
+		return nil
+	}
+	return me.Client.Close()
+}
+
+// Download retrieves the file with RETR command
+func (me *ftpProtocol) Download(ctx context.Context, f RemoteFile) (Content, error) {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if !f.Options().TempFile {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(me.contextError(ctx, client.Retrieve(f.URL().Path, pw)))
+		}()
+
+		return StreamContent(f, newReadCloser(pr, func() error {
+			pr.Close()
+			return client.Close()
+		})), nil
+	}
+
+	defer client.Close()
+	return DownloadToTempFile(f, func(tmp *os.File) error {
+		return me.contextError(ctx, client.Retrieve(f.URL().Path, tmp))
+	})
+}
+
+// Retryable retries on 4xx transient negative replies, and the transient network failures
+func (me *ftpProtocol) Retryable(err error) bool {
+	var ftpErr goftp.Error
+	if errors.As(err, &ftpErr) && ftpErr.Temporary() {
+		return true
+	}
+	return IsTransientError(err)
+}
+
+// contextError prefers the context error, because the transfer error is just a consequence of the cancellation
+func (me *ftpProtocol) contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// Upload stores the content with STOR command
+func (me *ftpProtocol) Upload(ctx context.Context, f RemoteFile, reader io.Reader) (int64, error) {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	counter := newCountingReader(reader)
+	err = client.Store(f.URL().Path, counter)
+	return counter.Count(), err
+}
+
+// Stat sends SIZE and MDTM commands. SIZE doesn't apply to directories, so CWD tells if it is a directory.
+func (me *ftpProtocol) Stat(ctx context.Context, f RemoteFile) (os.FileInfo, error) {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	conn, err := client.OpenRawConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	path := f.URL().Path
+	r := &RemoteFileInfoT{Filename: f.Name(), Length: -1}
+
+	if _, _, err := conn.SendCommand("TYPE I"); err != nil {
+		return nil, err
+	}
+
+	code, msg, err := conn.SendCommand("SIZE %s", path)
+	if err != nil {
+		return nil, err
+	}
+	if code != 213 {
+		if code, _, err = conn.SendCommand("CWD %s", path); err != nil {
+			return nil, err
+		}
+		if code != 250 {
+			return nil, os.ErrNotExist
+		}
+		r.Directory = true
+		return r, nil
+	}
+	if r.Length, err = strconv.ParseInt(strings.TrimSpace(msg), 10, 64); err != nil {
+		return nil, err
+	}
+
+	code, msg, err = conn.SendCommand("MDTM %s", path)
+	if err != nil {
+		return nil, err
+	}
+	// some servers reply 250 instead of 213
+	if msg = strings.TrimSpace(msg); (code == 213 || code == 250) && len(msg) >= 14 {
+		r.Lastmod, _ = time.Parse("20060102150405", msg[:14])
+	}
+	return r, nil
+}
+
+func (me *ftpProtocol) List(ctx context.Context, f RemoteFile) ([]os.FileInfo, error) {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	entries, err := client.ReadDir(f.URL().Path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		r = append(r, &RemoteFileInfoT{
+			Filename:  entry.Name(),
+			Length:    entry.Size(),
+			Lastmod:   entry.ModTime(),
+			Directory: entry.IsDir(),
+		})
+	}
+	return r, nil
+}
+
+// Remove sends DELE command, or RMD command if the path turns out to be a directory
+func (me *ftpProtocol) Remove(ctx context.Context, f RemoteFile) error {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	path := f.URL().Path
+	err = client.Delete(path)
+	if err != nil {
+		if rmdirErr := client.Rmdir(path); rmdirErr == nil {
+			return nil
+		}
+	}
+	return me.normalizeError(err)
+}
+
+// normalizeError translates 550 (file unavailable) to os.ErrNotExist
+func (me *ftpProtocol) normalizeError(err error) error {
+	var ftpErr goftp.Error
+	if errors.As(err, &ftpErr) && ftpErr.Code() == 550 {
+		return errors.Wrap(os.ErrNotExist, err.Error())
+	}
+	return err
+}
+
+// OpenRandom reads with REST and RETR commands on a dedicated control connection, one range at a time
+func (me *ftpProtocol) OpenRandom(ctx context.Context, f RemoteFile) (RandomReader, error) {
+	fi, err := me.Stat(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.OpenRawConn()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if _, _, err := conn.SendCommand("TYPE I"); err != nil {
+		conn.Close()
+		client.Close()
+		return nil, err
+	}
+
+	path := f.URL().Path
+	mutex := &sync.Mutex{}
+
+	readRange := func(offset int64, length int64) (io.ReadCloser, error) {
+		mutex.Lock()
+
+		dc, err := me.retrieveFrom(conn, path, offset)
+		if err != nil {
+			mutex.Unlock()
+			return nil, me.contextError(ctx, err)
+		}
+
+		var reader io.Reader = dc
+		if length >= 0 {
+			reader = io.LimitReader(dc, length)
+		}
+		return newReadCloser(reader, func() error {
+			defer mutex.Unlock()
+
+			err := dc.Close()
+			// either 226 transfer complete, or 426 transfer aborted
+			conn.ReadResponse()
+			return err
+		}), nil
+	}
+
+	return NewRangeReader(fi.Size(), readRange, func() error {
+		conn.Close()
+		return client.Close()
+	})
+}
+
+func (me *ftpProtocol) retrieveFrom(conn goftp.RawConn, path string, offset int64) (io.ReadCloser, error) {
+	code, msg, err := conn.SendCommand("REST %d", offset)
+	if err != nil {
+		return nil, err
+	}
+	if code != 350 {
+		return nil, fmt.Errorf("REST %d: %d %s", offset, code, msg)
+	}
+
+	getDataConn, err := conn.PrepareDataConn()
+	if err != nil {
+		return nil, err
+	}
+
+	code, msg, err = conn.SendCommand("RETR %s", path)
+	if err != nil {
+		return nil, err
+	}
+	if code < 100 || code > 199 {
+		return nil, fmt.Errorf("RETR %s: %d %s", path, code, msg)
+	}
+	return getDataConn()
+}
+
+func (me *ftpProtocol) dial(ctx context.Context, f RemoteFile) (ftpClient, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	credentials := f.Credentials()
+
+	config := goftp.Config{
+		Timeout:   f.Timeout(),
+		User:      credentials.User,
+		Password:  credentials.Password,
+		TLSConfig: credentials.TLSConfig,
+		TLSMode:   goftp.TLSMode(credentials.TLSMode),
+	}
+	client, err := goftp.DialConfig(config, f.URL().Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ftpClientT{
+		Client:    client,
+		stopWatch: context.AfterFunc(ctx, func() { client.Close() }),
+	}, nil
+}
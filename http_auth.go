@@ -0,0 +1,241 @@
+package ufs
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Authenticator authenticates the http(s) requests, see WithAuthenticator()
+type Authenticator interface {
+	// Authenticate sets the authentication of the request, i.e, the Authorization header
+	Authenticate(req *http.Request) error
+}
+
+// ChallengeAuthenticator is the Authenticator which answers the challenge of 401 response, i.e, digest
+type ChallengeAuthenticator interface {
+	Authenticator
+
+	// Challenge takes the challenge of the 401 response, returns false if the challenge is not supported
+	Challenge(resp *http.Response) (bool, error)
+
+	// Challenged tells if a challenge has been taken, so that Authenticate() could answer it
+	Challenged() bool
+}
+
+// WithAuthenticator specifies the Authenticator of the http(s) requests. By default, the requests
+// are authenticated with basic auth if Credentials.Password is not empty.
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(options Options) {
+		options.Authenticator = authenticator
+	}
+}
+
+// AuthenticatorFunc adapts the function as Authenticator
+type AuthenticatorFunc func(req *http.Request) error
+
+func (me AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return me(req)
+}
+
+// BasicAuth authenticates with "Authorization: Basic ..."
+func BasicAuth(user string, password string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.SetBasicAuth(user, password)
+		return nil
+	})
+}
+
+// BearerAuth authenticates with "Authorization: Bearer <token>"
+func BearerAuth(token string) Authenticator {
+	return BearerAuthFunc(func() (string, error) {
+		return token, nil
+	})
+}
+
+// BearerAuthFunc authenticates with "Authorization: Bearer <token>", the token is got for each request,
+// so that it could be refreshed
+func BearerAuthFunc(token func() (string, error)) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		t, err := token()
+		if err != nil {
+			return errors.Wrap(err, "get bearer token")
+		}
+		req.Header.Set("Authorization", "Bearer "+t)
+		return nil
+	})
+}
+
+// ApiKeyAuth authenticates with the api key header, i.e, "X-API-Key: <key>"
+func ApiKeyAuth(header string, key string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set(header, key)
+		return nil
+	})
+}
+
+// digestAuthT is the http digest access authentication (RFC 7616), with MD5, SHA-256 and their -sess variants,
+// and qop "auth" if the server offers it
+type digestAuthT struct {
+	user     string
+	password string
+
+	mutex     sync.Mutex
+	challenge map[string]string
+	nc        int
+}
+
+type digestAuth = *digestAuthT
+
+// DigestAuth authenticates with http digest access authentication. The first request gets the
+// challenge from the 401 response, then it is resent; the subsequent requests answer the same challenge.
+func DigestAuth(user string, password string) ChallengeAuthenticator {
+	return &digestAuthT{user: user, password: password}
+}
+
+func (me digestAuth) Challenged() bool {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.challenge != nil
+}
+
+func (me digestAuth) Challenge(resp *http.Response) (bool, error) {
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		challenge := parseAuthParams(params)
+		if challenge["nonce"] == "" {
+			return false, errors.Errorf("digest challenge without nonce: %s", header)
+		}
+		if _, err := digestHash(challenge["algorithm"]); err != nil {
+			return false, err
+		}
+
+		me.mutex.Lock()
+		me.challenge = challenge
+		me.nc = 0
+		me.mutex.Unlock()
+		return true, nil
+	}
+	return false, nil
+}
+
+func (me digestAuth) Authenticate(req *http.Request) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	if me.challenge == nil {
+		return nil
+	}
+	me.nc++
+
+	algorithm := me.challenge["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	newHash, err := digestHash(algorithm)
+	if err != nil {
+		return err
+	}
+	h := func(s string) string {
+		hasher := newHash()
+		hasher.Write([]byte(s))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	realm, nonce := me.challenge["realm"], me.challenge["nonce"]
+	uri := req.URL.RequestURI()
+	nc := fmt.Sprintf("%08x", me.nc)
+
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+
+	ha1 := h(me.user + ":" + realm + ":" + me.password)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+
+	qop := ""
+	for _, q := range strings.Split(me.challenge["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, response="%s"`,
+		me.user, realm, nonce, uri, algorithm, response)
+	if qop != "" {
+		header += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+	if opaque, found := me.challenge["opaque"]; found {
+		header += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	req.Header.Set("Authorization", header)
+	return nil
+}
+
+func digestHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToUpper(algorithm) {
+	case "", "MD5", "MD5-SESS":
+		return md5.New, nil
+	case "SHA-256", "SHA-256-SESS":
+		return sha256.New, nil
+	}
+	return nil, errors.Errorf("unsupported digest algorithm: %s", algorithm)
+}
+
+// parseAuthParams parses the comma-separated auth params, i.e, `realm="a, b", nonce="x", stale=false`
+func parseAuthParams(s string) map[string]string {
+	r := map[string]string{}
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		name, rest, found := strings.Cut(s, "=")
+		if !found {
+			break
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimSpace(rest)
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				b.WriteByte(rest[i])
+			}
+			value, rest = b.String(), rest[min(i+1, len(rest)):]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+			rest = "," + rest
+		}
+		r[name] = value
+
+		_, s, _ = strings.Cut(rest, ",")
+	}
+	return r
+}
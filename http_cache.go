@@ -0,0 +1,393 @@
+package ufs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// HttpCacheT is the private http cache (RFC 9111) of the downloads, stored on afero.Fs. A cached
+// response is served without request while it is fresh per Cache-Control max-age or Expires;
+// afterwards it is revalidated with If-None-Match/If-Modified-Since, and served again on 304.
+// Responses with Cache-Control no-store, or without any validator or freshness, are not cached.
+// The entries are keyed by url, and by the variant of the request, that is, the headers specified by
+// WithHeader() and the identity which the request is authenticated with, so that the callers of different
+// headers or credentials do not share the response. Vary is not supported.
+type HttpCacheT struct {
+	fs    afero.Fs
+	dir   string
+	mutex sync.Mutex
+}
+
+type HttpCache = *HttpCacheT
+
+// httpCacheEntryT is the meta of a cached response, stored in yaml alongside the body
+type httpCacheEntryT struct {
+	Url          string `yaml:"url"`
+	Variant      string `yaml:"variant"`
+	ETag         string `yaml:"etag"`
+	LastModified string `yaml:"lastModified"`
+	// the response is fresh until then, zero means it must be revalidated
+	Expires time.Time `yaml:"expires"`
+	// the freshness lifetime, for the 304 response which does not tell it again
+	Lifetime time.Duration `yaml:"lifetime"`
+}
+
+type httpCacheEntry = *httpCacheEntryT
+
+// NewHttpCache creates the HttpCache in the directory of the fs
+func NewHttpCache(fs afero.Fs, dir string) HttpCache {
+	return &HttpCacheT{fs: fs, dir: dir}
+}
+
+// WithHttpCache caches the http(s) downloads
+func WithHttpCache(cache HttpCache) Option {
+	return func(options Options) {
+		options.HttpCache = cache
+	}
+}
+
+// urlDir returns the directory of the entries of the url, one entry for each variant
+func (me HttpCache) urlDir(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(me.dir, hex.EncodeToString(sum[:]))
+}
+
+// paths returns the body path and the meta path of the url and the variant
+func (me HttpCache) paths(url string, variant string) (string, string) {
+	dir := me.urlDir(url)
+	return filepath.Join(dir, variant), filepath.Join(dir, variant+".yaml")
+}
+
+func (me HttpCache) RemoveP(url string) {
+	if err := me.Remove(url); err != nil {
+		panic(err)
+	}
+}
+
+// Remove deletes the cached responses of the url, of all variants
+func (me HttpCache) Remove(url string) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	dir := me.urlDir(url)
+	if err := me.fs.RemoveAll(dir); err != nil {
+		return errors.Wrapf(err, "remove http cache dir: %s", dir)
+	}
+	return nil
+}
+
+// removeVariant deletes the cached response of the url and the variant
+func (me HttpCache) removeVariant(url string, variant string) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	bodyPath, metaPath := me.paths(url, variant)
+	if err := RemoveFile(me.fs, metaPath); err != nil {
+		return err
+	}
+	return RemoveFile(me.fs, bodyPath)
+}
+
+// load returns the cache entry of the url and the variant, nil if not cached
+func (me HttpCache) load(url string, variant string) httpCacheEntry {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	_, metaPath := me.paths(url, variant)
+	metaYaml, err := afero.ReadFile(me.fs, metaPath)
+	if err != nil {
+		return nil
+	}
+
+	r := &httpCacheEntryT{}
+	if err := yaml.Unmarshal(metaYaml, r); err != nil || r.Url != url || r.Variant != variant {
+		return nil
+	}
+	return r
+}
+
+// open opens the cached body
+func (me HttpCache) open(url string, variant string) (afero.File, error) {
+	bodyPath, _ := me.paths(url, variant)
+	return me.fs.Open(bodyPath)
+}
+
+func (me HttpCache) saveEntry(entry httpCacheEntry) error {
+	metaYaml, err := yaml.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "marshal http cache entry")
+	}
+	_, metaPath := me.paths(entry.Url, entry.Variant)
+	return Write(me.fs, metaPath, metaYaml)
+}
+
+// store caches the response, and closes the response body
+func (me HttpCache) store(url string, variant string, resp *http.Response) error {
+	defer resp.Body.Close()
+
+	dir := me.urlDir(url)
+	if fi, err := me.fs.Stat(dir); err == nil && !fi.IsDir() {
+		// the entry of the former layout which is keyed by url only
+		if err := RemoveFile(me.fs, dir+".yaml"); err != nil {
+			return err
+		}
+		if err := RemoveFile(me.fs, dir); err != nil {
+			return err
+		}
+	}
+	if err := me.fs.MkdirAll(dir, 0o755); err != nil {
+		return errors.Wrapf(err, "create http cache dir: %s", dir)
+	}
+
+	tmp, err := afero.TempFile(me.fs, dir, "tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "create http cache file")
+	}
+	_, err = io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		me.fs.Remove(tmp.Name())
+		return err
+	}
+
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	// the meta is removed first, so that a stale meta never comes with the new body
+	bodyPath, metaPath := me.paths(url, variant)
+	if err := RemoveFile(me.fs, metaPath); err != nil {
+		return err
+	}
+	if err := me.fs.Rename(tmp.Name(), bodyPath); err != nil {
+		me.fs.Remove(tmp.Name())
+		return errors.Wrapf(err, "rename http cache file: %s", bodyPath)
+	}
+
+	now := time.Now()
+	r := &httpCacheEntryT{
+		Url:          url,
+		Variant:      variant,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Expires:      httpFreshUntil(resp, now),
+	}
+	if !r.Expires.IsZero() {
+		r.Lifetime = r.Expires.Sub(now)
+	}
+	return me.saveEntry(r)
+}
+
+// revalidated updates the cache entry with the 304 response
+func (me HttpCache) revalidated(entry httpCacheEntry, resp *http.Response) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		entry.ETag = etag
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		entry.LastModified = lastModified
+	}
+
+	now := time.Now()
+	if resp.Header.Get("Cache-Control") == "" && resp.Header.Get("Expires") == "" {
+		if entry.Lifetime > 0 {
+			entry.Expires = now.Add(entry.Lifetime)
+		}
+	} else {
+		entry.Expires = httpFreshUntil(resp, now)
+		entry.Lifetime = 0
+		if !entry.Expires.IsZero() {
+			entry.Lifetime = entry.Expires.Sub(now)
+		}
+	}
+	return me.saveEntry(entry)
+}
+
+// download gets the file through the cache
+func (me HttpCache) download(ctx context.Context, p *httpProtocol, f RemoteFile) (Content, error) {
+	url := f.Url()
+
+	// authenticates first, for the identity of the variant
+	req, err := p.newRequest(ctx, f, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	authenticator, private := p.authenticator(f), privateHeaders(f)
+	if authenticator != nil {
+		if err := authenticate(authenticator, req, private); err != nil {
+			return nil, err
+		}
+	}
+	variant := httpCacheVariant(f, authenticator, req)
+
+	entry := me.load(url, variant)
+	if entry != nil && time.Now().Before(entry.Expires) {
+		if body, err := me.open(url, variant); err == nil {
+			return StreamContent(f, body), nil
+		}
+		entry = nil
+	}
+
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := p.sendAuthenticated(f, req, authenticator, private)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		if body, err := me.open(url, variant); err == nil {
+			if err := me.revalidated(entry, resp); err != nil {
+				body.Close()
+				return nil, err
+			}
+			return StreamContent(f, body), nil
+		}
+
+		// the body is gone, so download it again unconditionally
+		if err := me.removeVariant(url, variant); err != nil {
+			return nil, err
+		}
+		return me.download(ctx, p, f)
+	}
+
+	if err := p.checkStatus(f, req, resp); err != nil {
+		return nil, err
+	}
+
+	if !httpCacheable(resp) {
+		if err := me.removeVariant(url, variant); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return p.content(f, resp)
+	}
+
+	if err := me.store(url, variant, resp); err != nil {
+		return nil, err
+	}
+	body, err := me.open(url, variant)
+	if err != nil {
+		return nil, err
+	}
+	return StreamContent(f, body), nil
+}
+
+// httpCacheVariant returns the digest of the request headers specified by WithHeader(), and the identity
+// of the authenticated request: the headers set by the authenticator, or the user of digest auth whose
+// header differs for each request
+func httpCacheVariant(f RemoteFile, authenticator Authenticator, req *http.Request) string {
+	h := sha256.New()
+
+	writeHeaders := func(header http.Header, names []string) {
+		slices.Sort(names)
+		for _, name := range names {
+			for _, value := range header.Values(name) {
+				io.WriteString(h, name+": "+value+"\n")
+			}
+		}
+	}
+
+	headers := f.Options().Headers
+	writeHeaders(headers, slices.Collect(maps.Keys(headers)))
+	io.WriteString(h, "\n")
+
+	if digest, isDigest := authenticator.(digestAuth); isDigest {
+		io.WriteString(h, "digest "+digest.user+"\n"+digest.password)
+	} else if authenticator != nil {
+		var names []string
+		for name := range req.Header {
+			if _, specified := headers[name]; !specified {
+				names = append(names, name)
+			}
+		}
+		writeHeaders(req.Header, names)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseCacheControl parses the Cache-Control header into the directives, i.e, {"max-age": "60", "no-cache": ""}
+func parseCacheControl(header string) map[string]string {
+	r := map[string]string{}
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if name != "" {
+			r[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return r
+}
+
+// httpCacheable tells if the 200 response could be cached
+func httpCacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if _, noStore := parseCacheControl(resp.Header.Get("Cache-Control"))["no-store"]; noStore {
+		return false
+	}
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "" || httpFreshUntil(resp, time.Now()).After(time.Now())
+}
+
+// httpFreshUntil returns the time until which the response is fresh: by max-age, otherwise by Expires,
+// minus the Age. Zero if it must be revalidated.
+func httpFreshUntil(resp *http.Response, now time.Time) time.Time {
+	cacheControl := parseCacheControl(resp.Header.Get("Cache-Control"))
+	if _, noCache := cacheControl["no-cache"]; noCache {
+		return time.Time{}
+	}
+
+	var lifetime time.Duration
+	if maxAge, found := cacheControl["max-age"]; found {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return time.Time{}
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if expires := resp.Header.Get("Expires"); expires != "" {
+		expiresTime, err := http.ParseTime(expires)
+		if err != nil {
+			return time.Time{}
+		}
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			date = now
+		}
+		lifetime = expiresTime.Sub(date)
+	} else {
+		return time.Time{}
+	}
+
+	if age, err := strconv.Atoi(resp.Header.Get("Age")); err == nil {
+		lifetime -= time.Duration(age) * time.Second
+	}
+	if lifetime <= 0 {
+		return time.Time{}
+	}
+	return now.Add(lifetime)
+}
@@ -0,0 +1,226 @@
+package ufs
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+)
+
+// httpIndexEntryT is the entry of nginx json autoindex (autoindex_format json)
+type httpIndexEntryT struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Mtime string `json:"mtime"`
+	Size  *int64 `json:"size"`
+}
+
+// parseJsonIndex parses nginx json autoindex
+func parseJsonIndex(body io.Reader) ([]os.FileInfo, error) {
+	var entries []httpIndexEntryT
+	if err := json.NewDecoder(body).Decode(&entries); err != nil {
+		return nil, errors.Wrap(err, "parse json index")
+	}
+
+	r := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		fi := &RemoteFileInfoT{Filename: entry.Name, Length: -1, Directory: entry.Type == "directory"}
+		if entry.Size != nil {
+			fi.Length = *entry.Size
+		} else if fi.Directory {
+			fi.Length = 0
+		}
+		fi.Lastmod, _ = http.ParseTime(entry.Mtime)
+		r = append(r, fi)
+	}
+	return r, nil
+}
+
+// parseHtmlIndex parses the html autoindex, i.e, of nginx, Apache or python http.server. The entries
+// are the links to the direct children of the base url; the size and the modified time are parsed
+// from the text following the link, in the same line or table row, if any.
+func parseHtmlIndex(base *url.URL, body io.Reader) ([]os.FileInfo, error) {
+	base = &url.URL{Scheme: base.Scheme, Host: base.Host, Path: base.Path}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	var r []os.FileInfo
+	byName := map[string]RemoteFileInfo{}
+
+	var current RemoteFileInfo
+	var trailing strings.Builder
+	inLink, inRow := false, false
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		parseIndexDetails(current, trailing.String())
+
+		if existing, found := byName[current.Filename]; found {
+			if existing.Lastmod.IsZero() {
+				existing.Lastmod = current.Lastmod
+			}
+			if existing.Length < 0 {
+				existing.Length = current.Length
+			}
+		} else {
+			byName[current.Filename] = current
+			r = append(r, current)
+		}
+		current = nil
+		trailing.Reset()
+	}
+
+	tokenizer := html.NewTokenizer(body)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return nil, errors.Wrap(err, "parse html index")
+			}
+			flush()
+			return r, nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "a":
+				flush()
+				inLink = true
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						current = indexEntryOfLink(base, attr.Val)
+					}
+				}
+			case "tr":
+				flush()
+				inRow = true
+			}
+
+		case html.EndTagToken:
+			switch tokenizer.Token().Data {
+			case "a":
+				inLink = false
+			case "tr":
+				flush()
+				inRow = false
+			case "table", "ul", "pre":
+				flush()
+			}
+
+		case html.TextToken:
+			if current != nil && !inLink {
+				text := string(tokenizer.Text())
+				if line, _, multiline := strings.Cut(text, "\n"); multiline && !inRow {
+					// nginx lists an entry per line
+					trailing.WriteString(line)
+					flush()
+				} else {
+					trailing.WriteString(text)
+					trailing.WriteString(" ")
+				}
+			}
+		}
+	}
+}
+
+// indexEntryOfLink returns the entry if the link is to a direct child of the base url, otherwise nil
+func indexEntryOfLink(base *url.URL, href string) RemoteFileInfo {
+	ref, err := url.Parse(href)
+	if err != nil || ref.RawQuery != "" || (ref.Path == "" && ref.Fragment != "") {
+		return nil
+	}
+
+	target := base.ResolveReference(ref)
+	if target.Scheme != base.Scheme || target.Host != base.Host || !strings.HasPrefix(target.Path, base.Path) {
+		return nil
+	}
+
+	name := strings.TrimPrefix(target.Path, base.Path)
+	directory := strings.HasSuffix(name, "/")
+	name = strings.TrimSuffix(name, "/")
+	if name == "" || strings.Contains(name, "/") {
+		return nil
+	}
+
+	r := &RemoteFileInfoT{Filename: name, Length: -1, Directory: directory}
+	if directory {
+		r.Length = 0
+	}
+	return r
+}
+
+var indexTimeLayouts = []string{
+	"02-Jan-2006 15:04",    // nginx
+	"2006-01-02 15:04",     // Apache
+	"02-Jan-2006 15:04:05", // nginx with autoindex_localtime
+	"2006-01-02 15:04:05",
+}
+
+// parseIndexDetails parses the modified time, and the size following it, from the text after the link,
+// i.e, "01-Oct-2022 08:30    1234" of nginx or "2022-10-01 08:30  1.2K" of Apache
+func parseIndexDetails(fi RemoteFileInfo, text string) {
+	fields := strings.Fields(text)
+	for i := 0; i+1 < len(fields); i++ {
+		for _, layout := range indexTimeLayouts {
+			t, err := time.Parse(layout, fields[i]+" "+fields[i+1])
+			if err != nil {
+				continue
+			}
+
+			fi.Lastmod = t
+			if i+2 < len(fields) && !fi.Directory {
+				if size, ok := parseIndexSize(fields[i+2]); ok {
+					fi.Length = size
+				}
+			}
+			return
+		}
+	}
+}
+
+// parseIndexSize parses the size in bytes, or the approximate size with unit, i.e, "1.2K", "4M"
+func parseIndexSize(s string) (int64, bool) {
+	multiplier := float64(1)
+	switch unit := strings.ToUpper(s[len(s)-1:]); unit {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	case "T":
+		multiplier = 1 << 40
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return int64(n * multiplier), true
+}
+
+// listIndex parses the index page in the response, by its content type
+func listIndex(resp *http.Response) ([]os.FileInfo, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return parseJsonIndex(resp.Body)
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml" || mediaType == "":
+		return parseHtmlIndex(resp.Request.URL, resp.Body)
+	}
+	return nil, errors.Wrapf(ErrNotSupported, "list http directory of content type: %s", mediaType)
+}
@@ -0,0 +1,389 @@
+package ufs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/services"
+	"github.com/pkg/errors"
+)
+
+type httpProtocol struct{}
+
+// HttpStatusError is the error of non-2xx http response
+type HttpStatusError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Status     string
+	// the Retry-After header of 429 or 503 response, 0 if not specified
+	RetryAfter time.Duration
+}
+
+func (me *HttpStatusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", me.Method, me.Url, me.Status)
+}
+
+func (me *HttpStatusError) Unwrap() error {
+	if me.StatusCode == http.StatusNotFound || me.StatusCode == http.StatusGone {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// parseRetryAfter parses the Retry-After header, which is either delay seconds or http date
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// Retryable retries on 408, 429, 5xx except 501, and the transient network failures
+func (me *httpProtocol) Retryable(err error) bool {
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		case http.StatusNotImplemented, http.StatusHTTPVersionNotSupported:
+			return false
+		}
+		return statusErr.StatusCode >= 500
+	}
+	return IsTransientError(err)
+}
+
+// Download sends a GET request, through the HttpCache if specified
+func (me *httpProtocol) Download(ctx context.Context, f RemoteFile) (Content, error) {
+	if cache := f.Options().HttpCache; cache != nil {
+		return cache.download(ctx, me, f)
+	}
+
+	req, err := me.newRequest(ctx, f, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := me.do(f, req)
+	if err != nil {
+		return nil, err
+	}
+	return me.content(f, resp)
+}
+
+// content streams the response body, or buffers it into a temporary file with Options.TempFile
+func (me *httpProtocol) content(f RemoteFile, resp *http.Response) (Content, error) {
+	if !f.Options().TempFile {
+		return StreamContentOfLength(f, resp.Body, resp.ContentLength), nil
+	}
+
+	defer resp.Body.Close()
+	return DownloadToTempFile(f, func(tmp *os.File) error {
+		_, err := io.Copy(tmp, resp.Body)
+		return err
+	})
+}
+
+// Upload sends the content with a PUT request. The content could not be replayed, so
+// ChallengeAuthenticator gets the challenge with a HEAD request in advance.
+func (me *httpProtocol) Upload(ctx context.Context, f RemoteFile, reader io.Reader) (int64, error) {
+	if challenger, isChallenger := me.authenticator(f).(ChallengeAuthenticator); isChallenger && !challenger.Challenged() {
+		if req, err := me.newRequest(ctx, f, http.MethodHead, nil); err == nil {
+			if resp, err := me.do(f, req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+
+	counter := newCountingReader(reader)
+
+	req, err := me.newRequest(ctx, f, http.MethodPut, counter)
+	if err != nil {
+		return 0, err
+	}
+	if size := sizeOf(reader); size >= 0 {
+		req.ContentLength = size
+	}
+
+	resp, err := me.do(f, req)
+	if err != nil {
+		return counter.Count(), err
+	}
+	resp.Body.Close()
+
+	return counter.Count(), nil
+}
+
+// Stat sends a HEAD request
+func (me *httpProtocol) Stat(ctx context.Context, f RemoteFile) (os.FileInfo, error) {
+	req, err := me.newRequest(ctx, f, http.MethodHead, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := me.do(f, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	r := &RemoteFileInfoT{
+		Filename: f.Name(),
+		Length:   resp.ContentLength,
+		ETag:     resp.Header.Get("ETag"),
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		r.Lastmod, _ = http.ParseTime(lastModified)
+	}
+	return r, nil
+}
+
+// List parses the directory index page: the html autoindex of nginx, Apache and so on, or the json
+// autoindex of nginx. The size is -1 if the index page does not tell.
+func (me *httpProtocol) List(ctx context.Context, f RemoteFile) ([]os.FileInfo, error) {
+	req, err := me.newRequest(ctx, f, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "text/html, application/json;q=0.9")
+	}
+
+	resp, err := me.do(f, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return listIndex(resp)
+}
+
+// Remove sends a DELETE request
+func (me *httpProtocol) Remove(ctx context.Context, f RemoteFile) error {
+	req, err := me.newRequest(ctx, f, http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := me.do(f, req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// OpenRandom reads with Range requests
+func (me *httpProtocol) OpenRandom(ctx context.Context, f RemoteFile) (RandomReader, error) {
+	fi, err := me.Stat(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRangeReader(fi.Size(), func(offset int64, length int64) (io.ReadCloser, error) {
+		return me.readRange(ctx, f, offset, length)
+	}, nil)
+}
+
+func (me *httpProtocol) readRange(ctx context.Context, f RemoteFile, offset int64, length int64) (io.ReadCloser, error) {
+	req, err := me.newRequest(ctx, f, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", httpRange(offset, length))
+
+	resp, err := me.do(f, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		return resp.Body, nil
+	}
+
+	// the server ignores the range, so skip to the offset
+	if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return resp.Body, nil
+	}
+	return newReadCloser(io.LimitReader(resp.Body, length), resp.Body.Close), nil
+}
+
+func (me *httpProtocol) newRequest(ctx context.Context, f RemoteFile, method string, body io.Reader) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(ctx, method, httpUrl(f), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range f.Options().Headers {
+		if strings.EqualFold(name, "Host") {
+			r.Host = values[0]
+			continue
+		}
+		r.Header[name] = append([]string{}, values...)
+	}
+	return r, nil
+}
+
+// httpUrl returns the http(s) url of the file, i.e, http:// for dav://
+func httpUrl(f RemoteFile) string {
+	scheme := strings.ToLower(f.Protocol())
+	if scheme != services.HTTP && scheme != services.HTTPS {
+		u := *f.URL()
+		u.Scheme = services.HTTP
+		if scheme == DavsScheme {
+			u.Scheme = services.HTTPS
+		}
+		return u.String()
+	}
+	return f.Url()
+}
+
+// authenticator returns the Authenticator specified by option, otherwise basic auth if the password is not empty
+func (me *httpProtocol) authenticator(f RemoteFile) Authenticator {
+	if r := f.Options().Authenticator; r != nil {
+		return r
+	}
+
+	credentials := f.Credentials()
+	if credentials != nil && credentials.Password != "" {
+		return BasicAuth(credentials.User, credentials.Password)
+	}
+	return nil
+}
+
+// do sends the request, and treats any non-2xx status as error
+func (me *httpProtocol) do(f RemoteFile, req *http.Request) (*http.Response, error) {
+	r, err := me.send(f, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := me.checkStatus(f, req, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// checkStatus closes the response and returns HttpStatusError if the status is not 2xx
+func (me *httpProtocol) checkStatus(f RemoteFile, req *http.Request, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+
+	resp.Body.Close()
+	return &HttpStatusError{
+		Method:     req.Method,
+		Url:        f.Url(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// send authenticates and sends the request, see sendAuthenticated()
+func (me *httpProtocol) send(f RemoteFile, req *http.Request) (*http.Response, error) {
+	authenticator, private := me.authenticator(f), privateHeaders(f)
+	if authenticator != nil {
+		if err := authenticate(authenticator, req, private); err != nil {
+			return nil, err
+		}
+	}
+	return me.sendAuthenticated(f, req, authenticator, private)
+}
+
+// sendAuthenticated sends the request authenticated by the authenticator. With ChallengeAuthenticator,
+// the request is resent once to answer the challenge of 401 response, if its body could be replayed.
+// The private headers are not sent to another host on redirect.
+func (me *httpProtocol) sendAuthenticated(f RemoteFile, req *http.Request, authenticator Authenticator, private map[string]bool) (*http.Response, error) {
+	client := &http.Client{
+		Timeout:       f.Timeout(),
+		CheckRedirect: privateRedirectPolicy(private),
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenger, isChallenger := authenticator.(ChallengeAuthenticator); isChallenger && r.StatusCode == http.StatusUnauthorized {
+		replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		if challenged, err := challenger.Challenge(r); err != nil {
+			r.Body.Close()
+			return nil, err
+		} else if challenged && replayable {
+			r.Body.Close()
+
+			retry := req.Clone(req.Context())
+			if req.GetBody != nil {
+				if retry.Body, err = req.GetBody(); err != nil {
+					return nil, err
+				}
+			}
+			if err := authenticate(challenger, retry, private); err != nil {
+				return nil, err
+			}
+			if r, err = client.Do(retry); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// privateHeaders returns the names of the headers specified by WithHeader(), except User-Agent, which
+// are private to the original host as the headers set by the authenticator are, see authenticate()
+func privateHeaders(f RemoteFile) map[string]bool {
+	r := map[string]bool{}
+	for name := range f.Options().Headers {
+		if !strings.EqualFold(name, "User-Agent") {
+			r[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return r
+}
+
+// authenticate authenticates the request, and collects the names of the headers set by the authenticator
+func authenticate(authenticator Authenticator, req *http.Request, private map[string]bool) error {
+	before := req.Header.Clone()
+	if err := authenticator.Authenticate(req); err != nil {
+		return err
+	}
+	for name, values := range req.Header {
+		if !slices.Equal(before[name], values) {
+			private[name] = true
+		}
+	}
+	return nil
+}
+
+// privateRedirectPolicy follows up to 10 redirects as the default policy does, but removes the private
+// headers once redirected to another host, as the standard library does for Authorization and Cookie
+func privateRedirectPolicy(private map[string]bool) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+			for name := range private {
+				req.Header.Del(name)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+package ufs
+
+import (
+	"context"
+	"io"
+)
+
+// contextReaderT stops reading once the context is done
+type contextReaderT struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+type contextReader = *contextReaderT
+
+func newContextReader(ctx context.Context, reader io.Reader) contextReader {
+	return &contextReaderT{ctx: ctx, reader: reader}
+}
+
+func (me contextReader) Read(p []byte) (int, error) {
+	if err := me.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return me.reader.Read(p)
+}
+
+// readCloserT reads from the reader, and closes with the close function
+type readCloserT struct {
+	reader io.Reader
+	close  func() error
+}
+
+type readCloser = *readCloserT
+
+func newReadCloser(reader io.Reader, close func() error) readCloser {
+	return &readCloserT{reader: reader, close: close}
+}
+
+func (me readCloser) Read(p []byte) (int, error) {
+	return me.reader.Read(p)
+}
+
+func (me readCloser) Close() error {
+	return me.close()
+}
+
+type countingReaderT struct {
+	reader io.Reader
+	count  int64
+}
+
+type countingReader = *countingReaderT
+
+func newCountingReader(reader io.Reader) countingReader {
+	return &countingReaderT{reader: reader}
+}
+
+func (me countingReader) Read(p []byte) (int, error) {
+	n, err := me.reader.Read(p)
+	me.count += int64(n)
+	return n, err
+}
+
+func (me countingReader) Count() int64 {
+	return me.count
+}
+
+// pipeWriterT turns a reader consumer, i.e. an upload, into an io.WriteCloser.
+// The consumer runs in background and Close() waits for its result.
+type pipeWriterT struct {
+	writer *io.PipeWriter
+	done   chan error
+}
+
+type pipeWriter = *pipeWriterT
+
+func newPipeWriter(consume func(reader io.Reader) error) pipeWriter {
+	pr, pw := io.Pipe()
+	r := &pipeWriterT{writer: pw, done: make(chan error, 1)}
+
+	go func() {
+		err := consume(pr)
+		pr.CloseWithError(err)
+		r.done <- err
+	}()
+
+	return r
+}
+
+func (me pipeWriter) Write(p []byte) (int, error) {
+	return me.writer.Write(p)
+}
+
+func (me pipeWriter) Close() error {
+	me.writer.Close()
+	return <-me.done
+}
@@ -0,0 +1,465 @@
+package ufs
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// MountPointT is a filesystem mounted on a MountFs
+type MountPointT struct {
+	// the absolute, slash-separated path of the mount point
+	Path string
+	// the filesystem as mounted, without the read-only wrapper
+	Fs       afero.Fs
+	ReadOnly bool
+
+	// the filesystem to route to, which is read-only if ReadOnly is true
+	routed afero.Fs
+}
+
+type MountPoint = *MountPointT
+
+// MountFsT is the afero.Fs composing multiple filesystems, which routes the paths to the filesystem
+// mounted on the longest matching mount point. The mounted filesystem sees the paths relative to
+// its mount point, i.e, "/etc/hosts" is "/hosts" for the filesystem mounted on "/etc"; to mount a
+// sub directory, use afero.NewBasePathFs(). The parent directories of the mount points exist virtually,
+// and the mount points are listed in their parent directories, so that afero.Walk() goes across them.
+type MountFsT struct {
+	mutex sync.RWMutex
+	// sorted by path, the root mount point "/" is always the first
+	mounts []MountPoint
+}
+
+type MountFs = *MountFsT
+
+var _ afero.Fs = (*MountFsT)(nil)
+var _ afero.Lstater = (*MountFsT)(nil)
+
+// NewMountFs creates a MountFs with the root filesystem mounted on "/", nil means a MemMapFs
+func NewMountFs(root afero.Fs) MountFs {
+	if root == nil {
+		root = afero.NewMemMapFs()
+	}
+	return &MountFsT{mounts: []MountPoint{{Path: "/", Fs: root, routed: root}}}
+}
+
+// cleanPath returns the absolute, slash-separated, clean path
+func (me MountFs) cleanPath(name string) string {
+	return path.Clean("/" + filepath.ToSlash(name))
+}
+
+func (me MountFs) MountP(point string, fs afero.Fs, readOnly bool) {
+	if err := me.Mount(point, fs, readOnly); err != nil {
+		panic(err)
+	}
+}
+
+// Mount mounts the filesystem on the mount point. If readOnly, the writes fail with syscall.EPERM
+func (me MountFs) Mount(point string, fs afero.Fs, readOnly bool) error {
+	point = me.cleanPath(point)
+
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	for _, mp := range me.mounts {
+		if mp.Path == point {
+			return errors.Errorf("already mounted: %s", point)
+		}
+	}
+
+	routed := fs
+	if readOnly {
+		routed = afero.NewReadOnlyFs(fs)
+	}
+	me.mounts = append(me.mounts, &MountPointT{Path: point, Fs: fs, ReadOnly: readOnly, routed: routed})
+	sort.Slice(me.mounts, func(i, j int) bool {
+		return me.mounts[i].Path < me.mounts[j].Path
+	})
+	return nil
+}
+
+func (me MountFs) UnmountP(point string) {
+	if err := me.Unmount(point); err != nil {
+		panic(err)
+	}
+}
+
+// Unmount removes the mount point. The root mount point "/" could not be unmounted.
+func (me MountFs) Unmount(point string) error {
+	point = me.cleanPath(point)
+	if point == "/" {
+		return errors.New("could not unmount the root")
+	}
+
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	for i, mp := range me.mounts {
+		if mp.Path == point {
+			me.mounts = append(me.mounts[:i], me.mounts[i+1:]...)
+			return nil
+		}
+	}
+	return errors.Errorf("not mounted: %s", point)
+}
+
+// Mounts returns the mount points, sorted by path
+func (me MountFs) Mounts() []MountPointT {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+
+	r := make([]MountPointT, len(me.mounts))
+	for i, mp := range me.mounts {
+		r[i] = *mp
+	}
+	return r
+}
+
+// Resolve returns the mount point of the path, and the path relative to the mount point
+func (me MountFs) Resolve(name string) (MountPointT, string) {
+	mp, rel := me.resolve(me.cleanPath(name))
+	return *mp, rel
+}
+
+func (me MountFs) resolve(p string) (MountPoint, string) {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+
+	r := me.mounts[0]
+	for _, mp := range me.mounts[1:] {
+		if (p == mp.Path || strings.HasPrefix(p, mp.Path+"/")) && len(mp.Path) > len(r.Path) {
+			r = mp
+		}
+	}
+	return r, me.cleanPath(strings.TrimPrefix(p, r.Path))
+}
+
+// childMounts returns the names of the entries in the directory, which are mount points or the parents of mount points
+func (me MountFs) childMounts(dir string) map[string]MountPoint {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+
+	r := map[string]MountPoint{}
+	for _, mp := range me.mounts[1:] {
+		if !strings.HasPrefix(mp.Path, prefix) {
+			continue
+		}
+		child, rest, nested := strings.Cut(mp.Path[len(prefix):], "/")
+		if nested && rest != "" {
+			if _, found := r[child]; !found {
+				r[child] = nil
+			}
+		} else {
+			r[child] = mp
+		}
+	}
+	return r
+}
+
+// hasMountsUnder tells if any mount point is the path or under the path
+func (me MountFs) hasMountsUnder(p string) bool {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+
+	for _, mp := range me.mounts[1:] {
+		if mp.Path == p || strings.HasPrefix(mp.Path, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// pathError reports the error with the path of MountFs, instead of the path of the mounted filesystem
+func (me MountFs) pathError(op string, name string, err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		op = pathErr.Op
+	}
+	return newPathError(op, name, err)
+}
+
+// virtualDirInfo is the info of the parent directories of mount points, which do not exist in the filesystems
+func (me MountFs) virtualDirInfo(p string) os.FileInfo {
+	return &RemoteFileInfoT{Filename: path.Base(p), Length: 0, Directory: true}
+}
+
+func (me MountFs) Name() string {
+	return "MountFs"
+}
+
+func (me MountFs) Stat(name string) (os.FileInfo, error) {
+	p := me.cleanPath(name)
+	mp, rel := me.resolve(p)
+
+	r, err := mp.routed.Stat(rel)
+	if err != nil {
+		if os.IsNotExist(err) && len(me.childMounts(p)) > 0 {
+			return me.virtualDirInfo(p), nil
+		}
+		return nil, me.pathError("stat", name, err)
+	}
+	return r, nil
+}
+
+// LstatIfPossible calls Lstat if the mounted filesystem supports it, otherwise Stat
+func (me MountFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	p := me.cleanPath(name)
+	mp, rel := me.resolve(p)
+
+	lstater, isLstater := mp.routed.(afero.Lstater)
+	if !isLstater {
+		r, err := me.Stat(name)
+		return r, false, err
+	}
+
+	r, lstatCalled, err := lstater.LstatIfPossible(rel)
+	if err != nil {
+		if os.IsNotExist(err) && len(me.childMounts(p)) > 0 {
+			return me.virtualDirInfo(p), false, nil
+		}
+		return nil, lstatCalled, me.pathError("lstat", name, err)
+	}
+	return r, lstatCalled, nil
+}
+
+func (me MountFs) Create(name string) (afero.File, error) {
+	mp, rel := me.resolve(me.cleanPath(name))
+
+	r, err := mp.routed.Create(rel)
+	if err != nil {
+		return nil, me.pathError("open", name, err)
+	}
+	return r, nil
+}
+
+func (me MountFs) Open(name string) (afero.File, error) {
+	return me.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (me MountFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	p := me.cleanPath(name)
+	mp, rel := me.resolve(p)
+	children := me.childMounts(p)
+
+	r, err := mp.routed.OpenFile(rel, flag, perm)
+	if err != nil {
+		if os.IsNotExist(err) && len(children) > 0 && flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+			return newDirFile(name, func() (os.FileInfo, error) {
+				return me.virtualDirInfo(p), nil
+			}, func() ([]os.FileInfo, error) {
+				return me.mergeChildMounts(p, nil, children)
+			}), nil
+		}
+		return nil, me.pathError("open", name, err)
+	}
+
+	if len(children) == 0 {
+		return r, nil
+	}
+	fi, err := r.Stat()
+	if err != nil || !fi.IsDir() {
+		return r, nil
+	}
+
+	return &mountDirT{File: r, merged: newDirFile(name, r.Stat, func() ([]os.FileInfo, error) {
+		entries, err := r.Readdir(-1)
+		if err != nil {
+			return nil, err
+		}
+		return me.mergeChildMounts(p, entries, children)
+	})}, nil
+}
+
+// mergeChildMounts adds the child mount points into the directory entries, the mount point
+// shadows the entry of same name
+func (me MountFs) mergeChildMounts(dir string, entries []os.FileInfo, children map[string]MountPoint) ([]os.FileInfo, error) {
+	r := make([]os.FileInfo, 0, len(entries)+len(children))
+	for _, entry := range entries {
+		if _, shadowed := children[entry.Name()]; !shadowed {
+			r = append(r, entry)
+		}
+	}
+
+	var names []string
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		childPath := path.Join(dir, name)
+		fi, err := me.Stat(childPath)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, &RemoteFileInfoT{Filename: name, Length: fi.Size(), Lastmod: fi.ModTime(), Directory: fi.IsDir()})
+	}
+	return r, nil
+}
+
+func (me MountFs) Mkdir(name string, perm os.FileMode) error {
+	mp, rel := me.resolve(me.cleanPath(name))
+	if err := mp.routed.Mkdir(rel, perm); err != nil {
+		return me.pathError("mkdir", name, err)
+	}
+	return nil
+}
+
+func (me MountFs) MkdirAll(name string, perm os.FileMode) error {
+	mp, rel := me.resolve(me.cleanPath(name))
+	if err := mp.routed.MkdirAll(rel, perm); err != nil {
+		return me.pathError("mkdir", name, err)
+	}
+	return nil
+}
+
+// Remove deletes the file or the empty directory. Mount points and their parents could not be removed.
+func (me MountFs) Remove(name string) error {
+	p := me.cleanPath(name)
+	if me.hasMountsUnder(p) {
+		return newPathError("remove", name, syscall.EBUSY)
+	}
+
+	mp, rel := me.resolve(p)
+	if err := mp.routed.Remove(rel); err != nil {
+		return me.pathError("remove", name, err)
+	}
+	return nil
+}
+
+// RemoveAll deletes the path with everything in it. Mount points and their parents could not be removed.
+func (me MountFs) RemoveAll(name string) error {
+	p := me.cleanPath(name)
+	if me.hasMountsUnder(p) {
+		return newPathError("remove", name, syscall.EBUSY)
+	}
+
+	mp, rel := me.resolve(p)
+	if err := mp.routed.RemoveAll(rel); err != nil {
+		return me.pathError("remove", name, err)
+	}
+	return nil
+}
+
+// Rename moves the file or directory. Across the mount points, it copies then deletes the source.
+// Mount points and their parents could not be renamed.
+func (me MountFs) Rename(oldname string, newname string) error {
+	oldPath, newPath := me.cleanPath(oldname), me.cleanPath(newname)
+	if me.hasMountsUnder(oldPath) {
+		return newPathError("rename", oldname, syscall.EBUSY)
+	}
+
+	oldMp, oldRel := me.resolve(oldPath)
+	newMp, newRel := me.resolve(newPath)
+
+	if oldMp == newMp {
+		if err := oldMp.routed.Rename(oldRel, newRel); err != nil {
+			return me.pathError("rename", oldname, err)
+		}
+		return nil
+	}
+
+	if newMp.ReadOnly {
+		return newPathError("rename", newname, syscall.EPERM)
+	}
+	if oldMp.ReadOnly {
+		return newPathError("rename", oldname, syscall.EPERM)
+	}
+
+	if err := copyTree(oldMp.routed, oldRel, newMp.routed, newRel); err != nil {
+		return newPathError("rename", oldname, err)
+	}
+	if err := oldMp.routed.RemoveAll(oldRel); err != nil {
+		return me.pathError("rename", oldname, err)
+	}
+	return nil
+}
+
+// copyTree copies the file, or the directory recursively, between the filesystems
+func copyTree(srcFs afero.Fs, src string, dstFs afero.Fs, dst string) error {
+	return afero.Walk(srcFs, src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := path.Join(dst, filepath.ToSlash(rel))
+
+		if fi.IsDir() {
+			return dstFs.MkdirAll(target, fi.Mode().Perm())
+		}
+		return copyFileBetween(srcFs, p, dstFs, target, fi.Mode().Perm())
+	})
+}
+
+func copyFileBetween(srcFs afero.Fs, src string, dstFs afero.Fs, dst string, perm os.FileMode) error {
+	r, err := srcFs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := dstFs.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (me MountFs) Chmod(name string, mode os.FileMode) error {
+	mp, rel := me.resolve(me.cleanPath(name))
+	if err := mp.routed.Chmod(rel, mode); err != nil {
+		return me.pathError("chmod", name, err)
+	}
+	return nil
+}
+
+func (me MountFs) Chown(name string, uid int, gid int) error {
+	mp, rel := me.resolve(me.cleanPath(name))
+	if err := mp.routed.Chown(rel, uid, gid); err != nil {
+		return me.pathError("chown", name, err)
+	}
+	return nil
+}
+
+func (me MountFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	mp, rel := me.resolve(me.cleanPath(name))
+	if err := mp.routed.Chtimes(rel, atime, mtime); err != nil {
+		return me.pathError("chtimes", name, err)
+	}
+	return nil
+}
+
+// mountDirT is the directory opened from the mounted filesystem, with the child mount points listed as well
+type mountDirT struct {
+	afero.File
+	merged dirFile
+}
+
+func (me *mountDirT) Readdir(count int) ([]os.FileInfo, error) {
+	return me.merged.Readdir(count)
+}
+
+func (me *mountDirT) Readdirnames(n int) ([]string, error) {
+	return me.merged.Readdirnames(n)
+}
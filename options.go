@@ -0,0 +1,186 @@
+package ufs
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/qiangyt/go-comm/v2"
+)
+
+// OptionsT holds the optional settings of a File
+type OptionsT struct {
+	// buffers remote downloads into a temporary file, instead of streaming directly from the remote
+	TempFile bool
+
+	// observes the progress of downloads and uploads, nil means no progress reporting
+	Progress ProgressFunc
+
+	// retries the failed remote operations, nil means DefaultRetryPolicy()
+	Retry RetryPolicy
+
+	// logs the retries, could be nil
+	Logger comm.Logger
+
+	// configures the S3 client, nil means the default configuration
+	S3 S3Config
+
+	// the extra headers of the http(s) requests
+	Headers http.Header
+
+	// authenticates the http(s) requests, nil means basic auth with Credentials
+	Authenticator Authenticator
+
+	// caches the http(s) downloads, nil means no cache
+	HttpCache HttpCache
+
+	// resolves the credentials if not specified, nil means DefaultCredentialsProvider()
+	CredentialsProvider CredentialsProvider
+
+	// the expected digest of the downloaded content, nil means not verified unless ChecksumsFile is specified
+	Checksum Checksum
+
+	// the checksums file in the same directory, i.e, "SHA256SUMS", where the expected digest is looked up
+	ChecksumsFile string
+
+	// the trusted keys of the detached signature (<url>.sig), empty means the signature is not verified
+	TrustedKeys []SignatureKey
+
+	// masks the permission of the files and directories created on the remote, i.e, by SftpFs,
+	// nil means DefaultUmask
+	Umask *os.FileMode
+}
+
+type Options = *OptionsT
+
+type Option func(options Options)
+
+func NewOptions(options ...Option) Options {
+	r := &OptionsT{}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// WithTempFile makes remote downloads buffered into a temporary file, which is deleted once the
+// content blob is closed. By default, the content blob reads directly from the remote.
+func WithTempFile() Option {
+	return func(options Options) {
+		options.TempFile = true
+	}
+}
+
+// WithProgress reports the progress of downloads and uploads to the observer
+func WithProgress(observer ProgressFunc) Option {
+	return func(options Options) {
+		options.Progress = observer
+	}
+}
+
+// WithRetry specifies the RetryPolicy of remote operations, instead of DefaultRetryPolicy()
+func WithRetry(policy RetryPolicy) Option {
+	return func(options Options) {
+		options.Retry = policy
+	}
+}
+
+// WithLogger specifies the logger for the retries
+func WithLogger(logger comm.Logger) Option {
+	return func(options Options) {
+		options.Logger = logger
+	}
+}
+
+// WithHeader adds the header to the http(s) requests
+func WithHeader(name string, value string) Option {
+	return func(options Options) {
+		if options.Headers == nil {
+			options.Headers = http.Header{}
+		}
+		options.Headers.Add(name, value)
+	}
+}
+
+// WithUserAgent specifies the User-Agent header of the http(s) requests
+func WithUserAgent(userAgent string) Option {
+	return func(options Options) {
+		if options.Headers == nil {
+			options.Headers = http.Header{}
+		}
+		options.Headers.Set("User-Agent", userAgent)
+	}
+}
+
+// WithChecksum verifies the downloaded content against the hex digest, where the algorithm is
+// ChecksumSHA256, ChecksumSHA512 or ChecksumMD5. ChecksumMismatchError is returned by the content
+// blob once read to the end, if not matched.
+func WithChecksum(algorithm string, digest string) Option {
+	return func(options Options) {
+		options.Checksum = &ChecksumT{Algorithm: algorithm, Digest: digest}
+	}
+}
+
+// WithChecksumsFile verifies the downloaded content against the digest listed in the checksums file,
+// i.e, "SHA256SUMS", in the same directory. It is ignored if WithChecksum() is specified.
+func WithChecksumsFile(name string) Option {
+	return func(options Options) {
+		options.ChecksumsFile = name
+	}
+}
+
+// WithTrustedKeys verifies the downloaded content against the detached signature, the file name with
+// SignatureSuffix in the same directory, which is either of minisign or raw ed25519, see ParseSignatureKey().
+// The content is buffered in memory until verified, and SignatureError is returned by the download if
+// the content is unsigned or not signed by any of the trusted keys.
+func WithTrustedKeys(keys ...SignatureKey) Option {
+	return func(options Options) {
+		options.TrustedKeys = append(options.TrustedKeys, keys...)
+	}
+}
+
+// DefaultUmask masks the group and other write permission, same as the common umask of the shell
+const DefaultUmask os.FileMode = 0o022
+
+// WithUmask masks the permission of the files and directories created on the remote, i.e, by SftpFs.
+// The default is DefaultUmask, and 0 means the permission is applied as is.
+func WithUmask(umask os.FileMode) Option {
+	return func(options Options) {
+		options.Umask = &umask
+	}
+}
+
+// unverifiedOption copies the options without the verification of the content, i.e, the checksum and the signature
+func (me Options) unverifiedOption() Option {
+	return func(options Options) {
+		*options = *me
+		options.Checksum = nil
+		options.ChecksumsFile = ""
+		options.TrustedKeys = nil
+	}
+}
+
+// siblingOption copies the options for the sibling remote file, i.e, the checksums file or the signature, which itself is
+// neither verified nor reported to the progress observer
+func (me Options) siblingOption() Option {
+	unverified := me.unverifiedOption()
+	return func(options Options) {
+		unverified(options)
+		options.Progress = nil
+	}
+}
+
+// Perm returns the permission masked by the specified umask, or by DefaultUmask if not specified
+func (me Options) Perm(perm os.FileMode) os.FileMode {
+	if me.Umask != nil {
+		return perm &^ *me.Umask
+	}
+	return perm &^ DefaultUmask
+}
+
+// RetryPolicy returns the specified RetryPolicy, or DefaultRetryPolicy() if not specified
+func (me Options) RetryPolicy() RetryPolicy {
+	if me.Retry != nil {
+		return me.Retry
+	}
+	return DefaultRetryPolicy()
+}
@@ -0,0 +1,112 @@
+package ufs
+
+import (
+	"io"
+	"time"
+)
+
+// ProgressT is the snapshot of a transfer
+type ProgressT struct {
+	// bytes transferred so far
+	Done int64
+	// total bytes, -1 if unknown
+	Total int64
+	// average transfer rate, in bytes per second
+	Rate float64
+	// estimated time to finish, -1 if unknown
+	ETA time.Duration
+	// time elapsed since the transfer started
+	Elapsed time.Duration
+	// true for the last report, once the transfer is finished or failed
+	Finished bool
+}
+
+type Progress = *ProgressT
+
+// ProgressFunc observes the progress of a transfer
+type ProgressFunc func(progress Progress)
+
+// ProgressInterval is the minimum interval between two progress reports of a transfer
+var ProgressInterval = 200 * time.Millisecond
+
+// progressReaderT reports the progress while being read
+type progressReaderT struct {
+	reader   io.Reader
+	observer ProgressFunc
+	total    int64
+	offset   int64
+	done     int64
+	started  time.Time
+	reported time.Time
+	finished bool
+}
+
+type progressReader = *progressReaderT
+
+// newProgressReader wraps the reader with progress reporting. offset is the bytes already
+// transferred before the reader, i.e. a resumed download. Returns the reader as-is if observer is nil
+func newProgressReader(reader io.Reader, total int64, offset int64, observer ProgressFunc) io.Reader {
+	if observer == nil {
+		return reader
+	}
+	return &progressReaderT{reader: reader, observer: observer, total: total, offset: offset, started: time.Now()}
+}
+
+// withProgress is the io.ReadCloser version of newProgressReader
+func withProgress(rc io.ReadCloser, total int64, offset int64, observer ProgressFunc) io.ReadCloser {
+	if observer == nil {
+		return rc
+	}
+	return newReadCloser(newProgressReader(rc, total, offset, observer), rc.Close)
+}
+
+func (me progressReader) Read(p []byte) (int, error) {
+	n, err := me.reader.Read(p)
+	me.done += int64(n)
+
+	if err != nil {
+		me.report(true)
+	} else if time.Since(me.reported) >= ProgressInterval {
+		me.report(false)
+	}
+	return n, err
+}
+
+// Len returns the remaining bytes, -1 if unknown. It keeps the length of the wrapped reader visible
+func (me progressReader) Len() int {
+	if me.total < 0 {
+		return -1
+	}
+	return int(me.total - me.offset - me.done)
+}
+
+func (me progressReader) report(finished bool) {
+	if me.finished {
+		return
+	}
+	me.finished = finished
+
+	now := time.Now()
+	me.reported = now
+
+	r := &ProgressT{Done: me.offset + me.done, Total: me.total, ETA: -1, Elapsed: now.Sub(me.started), Finished: finished}
+	if seconds := r.Elapsed.Seconds(); seconds > 0 {
+		r.Rate = float64(me.done) / seconds
+	}
+	if r.Total >= 0 && r.Rate > 0 {
+		remaining := r.Total - r.Done
+		if remaining < 0 {
+			remaining = 0
+		}
+		r.ETA = time.Duration(float64(remaining) / r.Rate * float64(time.Second))
+	}
+	me.observer(r)
+}
+
+// sizeOf returns the remaining length of the reader if it tells, i.e. bytes.Reader, otherwise -1
+func sizeOf(reader io.Reader) int64 {
+	if sized, isSized := reader.(interface{ Len() int }); isSized {
+		return int64(sized.Len())
+	}
+	return -1
+}
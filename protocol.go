@@ -0,0 +1,167 @@
+package ufs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+	"github.com/goodsru/go-universal-network-adapter/services"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// ErrNotSupported tells the operation is not supported by the protocol
+var ErrNotSupported = errors.New("not supported")
+
+// Protocol implements the operations of a remote file for url schemes, see RegisterProtocol()
+type Protocol interface {
+	// Download retrieves the remote file content. The content blob should read directly from
+	// the remote unless Options.TempFile is set, see StreamContent() and DownloadToTempFile()
+	Download(ctx context.Context, f RemoteFile) (Content, error)
+
+	// Upload writes everything read from reader to the remote file, returns the amount of bytes written
+	Upload(ctx context.Context, f RemoteFile, reader io.Reader) (int64, error)
+
+	// Stat returns the remote file info, or an error wrapping os.ErrNotExist if not found
+	Stat(ctx context.Context, f RemoteFile) (os.FileInfo, error)
+
+	// List returns the entries of the remote directory
+	List(ctx context.Context, f RemoteFile) ([]os.FileInfo, error)
+
+	// Remove deletes the remote file, or the remote directory if it is empty
+	Remove(ctx context.Context, f RemoteFile) error
+
+	// OpenRandom opens the remote file for random access reading, see NewRangeReader()
+	OpenRandom(ctx context.Context, f RemoteFile) (RandomReader, error)
+
+	// Retryable tells if the failed operation is likely to succeed on retry, see RetryPolicy and IsTransientError()
+	Retryable(err error) bool
+}
+
+// DirMaker is the optional interface of Protocol, which creates the remote directory, see File.Mkdir()
+type DirMaker interface {
+	// Mkdir creates the remote directory, whose parent must exist. Returns an error wrapping
+	// os.ErrExist if it exists already.
+	Mkdir(ctx context.Context, f RemoteFile) error
+}
+
+var (
+	_protocols      map[string]Protocol
+	_protocolsMutex sync.RWMutex
+)
+
+func init() {
+	httpProtocol := &httpProtocol{}
+	ftpProtocol := &ftpProtocol{}
+
+	_protocols = map[string]Protocol{
+		services.HTTP:  httpProtocol,
+		services.HTTPS: httpProtocol,
+		services.FTP:   ftpProtocol,
+		services.FTPS:  ftpProtocol,
+		services.SFTP:  &sftpProtocol{},
+		services.S3:    &s3Protocol{},
+		DavScheme:      &davProtocol{},
+		DavsScheme:     &davProtocol{},
+	}
+}
+
+// RegisterProtocol registers the handler for the url scheme (case-insensitive, without "://"), replacing
+// the existing one if any, i.e, the built-in http handler. Urls of registered schemes are remote, see IsRemote().
+// Nil handler unregisters the scheme.
+func RegisterProtocol(scheme string, handler Protocol) {
+	scheme = strings.ToLower(scheme)
+
+	_protocolsMutex.Lock()
+	defer _protocolsMutex.Unlock()
+
+	if handler == nil {
+		delete(_protocols, scheme)
+	} else {
+		_protocols[scheme] = handler
+	}
+}
+
+// LookupProtocol returns the handler registered for the url scheme, or nil if not registered
+func LookupProtocol(scheme string) Protocol {
+	_protocolsMutex.RLock()
+	defer _protocolsMutex.RUnlock()
+
+	return _protocols[strings.ToLower(scheme)]
+}
+
+func protocolOf(scheme string) (Protocol, error) {
+	r := LookupProtocol(scheme)
+	if r == nil {
+		return nil, fmt.Errorf("unsupported protocol: %s", scheme)
+	}
+	return r, nil
+}
+
+// StreamContent is the content which reads directly from the remote
+func StreamContent(f RemoteFile, blob io.ReadCloser) Content {
+	return &ContentT{
+		Name: f.Name(),
+		Path: f.URL().Path,
+		Blob: blob,
+	}
+}
+
+// lengthBlobT is the content blob of the length told by the remote, i.e, Content-Length of the response
+type lengthBlobT struct {
+	io.ReadCloser
+	length int64
+}
+
+// StreamContentOfLength is StreamContent of the length told by the remote, -1 if unknown
+func StreamContentOfLength(f RemoteFile, blob io.ReadCloser, length int64) Content {
+	if length >= 0 {
+		blob = &lengthBlobT{ReadCloser: blob, length: length}
+	}
+	return StreamContent(f, blob)
+}
+
+// contentLength returns the length of the downloaded content blob without another request, -1 if unknown
+func contentLength(blob io.ReadCloser) int64 {
+	switch b := blob.(type) {
+	case *lengthBlobT:
+		return b.length
+	case *models.Blob:
+		if fi, err := os.Stat(b.FilePath); err == nil {
+			return fi.Size()
+		}
+	case afero.File:
+		if fi, err := b.Stat(); err == nil {
+			return fi.Size()
+		}
+	}
+	return -1
+}
+
+// DownloadToTempFile lets the retrieve function write the content into a temporary file,
+// the temporary file is deleted once the returned content blob is closed
+func DownloadToTempFile(f RemoteFile, retrieve func(tmp *os.File) error) (Content, error) {
+	tmp, err := os.CreateTemp("", f.Name()+".*")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temporary file")
+	}
+
+	err = retrieve(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return &ContentT{
+		Name: f.Name(),
+		Path: tmp.Name(),
+		Blob: &models.Blob{FilePath: tmp.Name()},
+	}, nil
+}
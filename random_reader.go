@@ -0,0 +1,149 @@
+package ufs
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// RandomReader reads the file content at arbitrary offsets, without downloading the whole content
+type RandomReader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+	Size() int64
+}
+
+// rangeReaderT is the RandomReader on top of ranged reads, i.e, http Range requests.
+// Sequential Read() keeps one open range till the end of file, until Seek() moves to another offset.
+type rangeReaderT struct {
+	size      int64
+	readRange func(offset int64, length int64) (io.ReadCloser, error)
+	close     func() error
+
+	mutex  sync.Mutex
+	offset int64
+	stream io.ReadCloser
+}
+
+type rangeReader = *rangeReaderT
+
+// NewRangeReader creates a RandomReader on top of ranged reads, for Protocol.OpenRandom(). readRange returns
+// the content from offset, length -1 means till the end of file. close releases the resources shared by
+// the ranges, could be nil.
+func NewRangeReader(size int64, readRange func(offset int64, length int64) (io.ReadCloser, error), close func() error) (RandomReader, error) {
+	if size < 0 {
+		return nil, errors.New("random access requires the content length, but it is unknown")
+	}
+	return &rangeReaderT{size: size, readRange: readRange, close: close}, nil
+}
+
+func (me rangeReader) Size() int64 {
+	return me.size
+}
+
+func (me rangeReader) Read(p []byte) (int, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	if me.offset >= me.size {
+		return 0, io.EOF
+	}
+
+	if me.stream == nil {
+		stream, err := me.readRange(me.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		me.stream = stream
+	}
+
+	n, err := me.stream.Read(p)
+	me.offset += int64(n)
+	return n, err
+}
+
+func (me rangeReader) Seek(offset int64, whence int) (int64, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	var r int64
+	switch whence {
+	case io.SeekStart:
+		r = offset
+	case io.SeekCurrent:
+		r = me.offset + offset
+	case io.SeekEnd:
+		r = me.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if r < 0 {
+		return 0, fmt.Errorf("negative position: %d", r)
+	}
+
+	if r != me.offset {
+		me.closeStream()
+		me.offset = r
+	}
+	return r, nil
+}
+
+func (me rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= me.size {
+		return 0, io.EOF
+	}
+
+	// some protocols (ftp) serves one range at a time
+	me.mutex.Lock()
+	me.closeStream()
+	me.mutex.Unlock()
+
+	length := int64(len(p))
+	if remaining := me.size - off; length > remaining {
+		length = remaining
+	}
+
+	rc, err := me.readRange(off, length)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	n, err := io.ReadFull(rc, p[:length])
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (me rangeReader) Close() error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	err := me.closeStream()
+	if me.close != nil {
+		if closeErr := me.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (me rangeReader) closeStream() error {
+	if me.stream == nil {
+		return nil
+	}
+	err := me.stream.Close()
+	me.stream = nil
+	return err
+}
+
+// httpRange returns the http Range header of the range, length -1 means till the end of file
+func httpRange(offset int64, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
@@ -1,41 +1,50 @@
 package ufs
 
 import (
+	"context"
+	"io"
 	"net/url"
+	"os"
+	"path"
 	"time"
 
 	"github.com/goodsru/go-universal-network-adapter/models"
-	"github.com/goodsru/go-universal-network-adapter/services"
 	"github.com/pkg/errors"
+	"github.com/qiangyt/go-comm/v2"
 )
 
-var _uniNwAdapter *services.UniversalNetworkAdapter
-
 type RemoteFileT struct {
-	backend *models.RemoteFile
+	backend     *models.RemoteFile
+	credentials Credentials
+	options     Options
 }
 
 type RemoteFile = *RemoteFileT
 
-func init() {
-	_uniNwAdapter = services.NewUniversalNetworkAdapter()
-}
-
-func NewRemoteFileP(url string, credentials Credentials, timeout time.Duration) RemoteFile {
-	r, err := NewRemoteFile(url, credentials, timeout)
+func NewRemoteFileP(url string, credentials Credentials, timeout time.Duration, options ...Option) RemoteFile {
+	r, err := NewRemoteFile(url, credentials, timeout, options...)
 	if err != nil {
 		panic(err)
 	}
 	return r
 }
 
-func NewRemoteFile(url string, credentials Credentials, timeout time.Duration) (RemoteFile, error) {
-	remoteFile, err := models.NewRemoteFile(models.NewDestination(url, credentials, &timeout))
+// NewRemoteFile creates the remote file. If the credentials is nil, it is resolved by Options.CredentialsProvider
+func NewRemoteFile(url string, credentials Credentials, timeout time.Duration, options ...Option) (RemoteFile, error) {
+	opts := NewOptions(options...)
+	if credentials == nil {
+		var err error
+		if credentials, err = resolveCredentials(url, opts.CredentialsProvider); err != nil {
+			return nil, err
+		}
+	}
+
+	remoteFile, err := models.NewRemoteFile(models.NewDestination(url, credentials.model(), &timeout))
 	if err != nil {
 		return nil, errors.Wrapf(err, "new remote file object")
 	}
 
-	return &RemoteFileT{remoteFile}, nil
+	return &RemoteFileT{backend: remoteFile, credentials: credentials, options: opts}, nil
 }
 
 func (me RemoteFile) Name() string {
@@ -69,13 +78,17 @@ func (me RemoteFile) URL() *url.URL {
 }
 
 func (me RemoteFile) Credentials() Credentials {
-	return &me.backend.ParsedDestination.Credentials
+	return me.credentials
 }
 
 func (me RemoteFile) Timeout() time.Duration {
 	return me.backend.ParsedDestination.Timeout
 }
 
+func (me RemoteFile) Options() Options {
+	return me.options
+}
+
 func (me RemoteFile) DownloadP() Content {
 	r, err := me.Download()
 	if err != nil {
@@ -85,10 +98,301 @@ func (me RemoteFile) DownloadP() Content {
 }
 
 func (me RemoteFile) Download() (Content, error) {
-	r, err := _uniNwAdapter.Download(me.backend)
+	return me.DownloadContext(context.Background())
+}
+
+func (me RemoteFile) DownloadContextP(ctx context.Context) Content {
+	r, err := me.DownloadContext(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// DownloadContext downloads the remote file, the download is aborted once the context is done
+func (me RemoteFile) DownloadContext(ctx context.Context) (Content, error) {
+	p, err := protocolOf(me.Protocol())
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, err := checksumOf(ctx, me, me.readSibling)
 	if err != nil {
 		return nil, errors.Wrapf(err, "download %s", me.Url())
 	}
 
+	var r Content
+	err = me.retry(ctx, p, "download", func() (err error) {
+		r, err = p.Download(ctx, me)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "download %s", me.Url())
+	}
+
+	total := contentLength(r.Blob)
+	r.Blob = withChecksum(r.Blob, me.Url(), checksum)
+	if observer := me.options.Progress; observer != nil {
+		r.Blob = withProgress(r.Blob, total, 0, observer)
+	}
+
+	if r.Blob, err = verifySignature(ctx, me, me.readSibling, r.Blob); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// readSibling downloads the file of the name in the same directory, with the same credentials and options
+func (me RemoteFile) readSibling(ctx context.Context, name string) (string, []byte, error) {
+	u := *me.URL()
+	u.Path = path.Join(path.Dir(u.Path), name)
+	u.RawPath = ""
+	u.RawQuery = ""
+
+	f, err := NewRemoteFile(u.String(), me.credentials, me.Timeout(), me.options.siblingOption())
+	if err != nil {
+		return u.String(), nil, err
+	}
+	c, err := f.DownloadContext(ctx)
+	if err != nil {
+		return f.Url(), nil, err
+	}
+	defer c.Blob.Close()
+
+	r, err := comm.ReadBytes(newContextReader(ctx, c.Blob))
+	return f.Url(), r, err
+}
+
+// unverified returns the same remote file, but the content is downloaded as-is without the verification
+func (me RemoteFile) unverified() (File, error) {
+	return NewRemoteFile(me.Url(), me.credentials, me.Timeout(), me.options.unverifiedOption())
+}
+
+func (me RemoteFile) UploadP(reader io.Reader) int64 {
+	r, err := me.Upload(reader)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Upload writes the content read from the reader to the remote file. The failed upload is retried
+// only if the reader is an io.Seeker, which is rewound before retrying
+func (me RemoteFile) Upload(reader io.Reader) (int64, error) {
+	p, err := protocolOf(me.Protocol())
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	upload := func() (r int64, err error) {
+		return p.Upload(ctx, me, newProgressReader(reader, sizeOf(reader), 0, me.options.Progress))
+	}
+
+	var r int64
+	if seeker, isSeeker := reader.(io.Seeker); !isSeeker {
+		r, err = upload()
+	} else if start, seekErr := seeker.Seek(0, io.SeekCurrent); seekErr != nil {
+		r, err = upload()
+	} else {
+		attempted := false
+		err = me.retry(ctx, p, "upload", func() (err error) {
+			if attempted {
+				if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+					return err
+				}
+			}
+			attempted = true
+			r, err = upload()
+			return err
+		})
+	}
+	if err != nil {
+		return r, errors.Wrapf(err, "upload %s", me.Url())
+	}
 	return r, nil
 }
+
+func (me RemoteFile) CreateP() io.WriteCloser {
+	r, err := me.Create()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Create opens the remote file for writing, the content is uploaded while being written.
+// Close() must be called to complete the upload.
+func (me RemoteFile) Create() (io.WriteCloser, error) {
+	if _, err := protocolOf(me.Protocol()); err != nil {
+		return nil, err
+	}
+
+	return newPipeWriter(func(reader io.Reader) error {
+		_, err := me.Upload(reader)
+		return err
+	}), nil
+}
+
+func (me RemoteFile) StatP() os.FileInfo {
+	r, err := me.Stat()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Stat returns the remote file info. If the file is not found, the error is a *os.PathError
+// so that os.IsNotExist() works with it.
+func (me RemoteFile) Stat() (os.FileInfo, error) {
+	p, err := protocolOf(me.Protocol())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var r os.FileInfo
+	err = me.retry(ctx, p, "stat", func() (err error) {
+		r, err = p.Stat(ctx, me)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, &os.PathError{Op: "stat", Path: me.Url(), Err: os.ErrNotExist}
+		}
+		return nil, errors.Wrapf(err, "stat %s", me.Url())
+	}
+	return r, nil
+}
+
+func (me RemoteFile) ExistsP() bool {
+	r, err := me.Exists()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (me RemoteFile) Exists() (bool, error) {
+	_, err := me.Stat()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (me RemoteFile) ListP() []os.FileInfo {
+	r, err := me.List()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// List returns the entries of the remote directory
+func (me RemoteFile) List() ([]os.FileInfo, error) {
+	p, err := protocolOf(me.Protocol())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var r []os.FileInfo
+	err = me.retry(ctx, p, "list", func() (err error) {
+		r, err = p.List(ctx, me)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "list %s", me.Url())
+	}
+	return r, nil
+}
+
+func (me RemoteFile) RemoveP() {
+	if err := me.Remove(); err != nil {
+		panic(err)
+	}
+}
+
+// Remove deletes the remote file, or the remote directory if it is empty.
+// Same as RemoveFile(), it is not an error if the file is not found.
+func (me RemoteFile) Remove() error {
+	p, err := protocolOf(me.Protocol())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	err = me.retry(ctx, p, "remove", func() error {
+		return p.Remove(ctx, me)
+	})
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return errors.Wrapf(err, "remove %s", me.Url())
+	}
+	return nil
+}
+
+func (me RemoteFile) MkdirP() {
+	if err := me.Mkdir(); err != nil {
+		panic(err)
+	}
+}
+
+// Mkdir creates the remote directory, if the protocol supports it, see DirMaker
+func (me RemoteFile) Mkdir() error {
+	p, err := protocolOf(me.Protocol())
+	if err != nil {
+		return err
+	}
+	maker, isMaker := p.(DirMaker)
+	if !isMaker {
+		return errors.Wrapf(ErrNotSupported, "mkdir %s", me.Url())
+	}
+
+	ctx := context.Background()
+	err = me.retry(ctx, p, "mkdir", func() error {
+		return maker.Mkdir(ctx, me)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "mkdir %s", me.Url())
+	}
+	return nil
+}
+
+func (me RemoteFile) OpenRandomP() RandomReader {
+	r, err := me.OpenRandom()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// OpenRandom opens the remote file for random access reading, which reads only the requested ranges
+func (me RemoteFile) OpenRandom() (RandomReader, error) {
+	p, err := protocolOf(me.Protocol())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var r RandomReader
+	err = me.retry(ctx, p, "open", func() (err error) {
+		r, err = p.OpenRandom(ctx, me)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", me.Url())
+	}
+	return r, nil
+}
+
+// retry runs the protocol operation with the RetryPolicy of the options
+func (me RemoteFile) retry(ctx context.Context, p Protocol, op string, action func() error) error {
+	return me.options.RetryPolicy().Do(ctx, me.options.Logger, op+" "+me.Url(), p.Retryable, action)
+}
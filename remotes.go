@@ -0,0 +1,309 @@
+package ufs
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/qiangyt/go-comm/v2"
+	"github.com/spf13/afero"
+)
+
+const (
+	// DefaultRemotesFile is the remotes config if $UFS_REMOTES_FILE is not set
+	DefaultRemotesFile = "~/.config/ufs/remotes.yaml"
+
+	// RemotesFileEnv specifies the remotes config
+	RemotesFileEnv = "UFS_REMOTES_FILE"
+)
+
+// RemoteT is a named endpoint, so that "<name>:path/to/file" expands to the url under Url, with the
+// credentials, timeout and S3 configuration of the remote. See LoadRemotesFile() for the config.
+type RemoteT struct {
+	Name string
+	// the base url, i.e, "sftp://example.com:2222/srv/data"
+	Url string
+	// nil means the credentials are resolved by CredentialsProvider
+	Credentials Credentials
+	// zero means the timeout is up to the caller
+	Timeout time.Duration
+	// nil means the S3 configuration is up to the caller
+	S3 S3Config
+}
+
+type Remote = *RemoteT
+
+// Expand returns the url of the path under the remote, i.e, "sftp://example.com:2222/srv/data/a/b.yaml" for "a/b.yaml"
+func (me Remote) Expand(p string) string {
+	if p == "" {
+		return me.Url
+	}
+
+	u, err := url.Parse(me.Url)
+	if err != nil {
+		return strings.TrimSuffix(me.Url, "/") + "/" + strings.TrimPrefix(p, "/")
+	}
+	u.Path = path.Join("/", u.Path, p)
+	if strings.HasSuffix(p, "/") {
+		u.Path += "/"
+	}
+	return u.String()
+}
+
+var (
+	_remotes      = map[string]Remote{}
+	_remotesMutex sync.RWMutex
+
+	// the remotes config is reloaded once modified
+	_remotesFile      map[string]Remote
+	_remotesFileKey   string
+	_remotesFileMutex sync.Mutex
+)
+
+// RegisterRemote registers the remote of the name, which takes precedence over the remotes config;
+// nil unregisters it
+func RegisterRemote(name string, remote Remote) {
+	_remotesMutex.Lock()
+	defer _remotesMutex.Unlock()
+
+	if remote == nil {
+		delete(_remotes, name)
+	} else {
+		remote.Name = name
+		_remotes[name] = remote
+	}
+}
+
+// LookupRemote returns the remote of the name, registered by RegisterRemote() or defined in
+// $UFS_REMOTES_FILE or DefaultRemotesFile; nil if not found
+func LookupRemote(name string) (Remote, error) {
+	_remotesMutex.RLock()
+	r := _remotes[name]
+	_remotesMutex.RUnlock()
+	if r != nil {
+		return r, nil
+	}
+
+	remotes, err := loadDefaultRemotesFile()
+	if err != nil {
+		return nil, err
+	}
+	return remotes[name], nil
+}
+
+// loadDefaultRemotesFile loads $UFS_REMOTES_FILE or DefaultRemotesFile, nil if not exists
+func loadDefaultRemotesFile() (map[string]Remote, error) {
+	p := os.Getenv(RemotesFileEnv)
+	if p == "" {
+		p = DefaultRemotesFile
+	}
+	p, err := ExpandHomePath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := afero.NewOsFs()
+	fi, err := fs.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "stat remotes file: %s", p)
+	}
+
+	_remotesFileMutex.Lock()
+	defer _remotesFileMutex.Unlock()
+
+	key := strings.Join([]string{p, fi.ModTime().String(), strconv.FormatInt(fi.Size(), 10)}, "\x00")
+	if key != _remotesFileKey {
+		remotes, err := LoadRemotesFile(fs, p)
+		if err != nil {
+			return nil, err
+		}
+		_remotesFile, _remotesFileKey = remotes, key
+	}
+	return _remotesFile, nil
+}
+
+// remoteRefRegexp matches "<name>:<path>". The name is at least 2 characters, so that the Windows
+// drive letter, i.e, "C:\dir", is not taken as a remote.
+var remoteRefRegexp = regexp.MustCompile(`^([A-Za-z0-9_][A-Za-z0-9_.-]+):(.*)$`)
+
+// ResolveRemote returns the remote and the expanded url if the url is "<name>:path/to/file" of a
+// registered remote; otherwise nil and the url as is
+func ResolveRemote(url string) (Remote, string, error) {
+	if strings.Contains(url, "://") {
+		return nil, url, nil
+	}
+	m := remoteRefRegexp.FindStringSubmatch(url)
+	if m == nil {
+		return nil, url, nil
+	}
+
+	remote, err := LookupRemote(m[1])
+	if err != nil || remote == nil {
+		return nil, url, err
+	}
+	return remote, remote.Expand(m[2]), nil
+}
+
+// expandRemote expands the "<name>:path/to/file" url. The credentials and the timeout of the remote
+// are used unless specified, and its S3 configuration unless specified by option.
+func expandRemote(url string, credentials Credentials, timeout time.Duration, options []Option) (string, Credentials, time.Duration, []Option, error) {
+	remote, url, err := ResolveRemote(url)
+	if err != nil || remote == nil {
+		return url, credentials, timeout, options, err
+	}
+
+	if credentials == nil && remote.Credentials != nil {
+		c := *remote.Credentials
+		credentials = &c
+	}
+	if timeout <= 0 {
+		timeout = remote.Timeout
+	}
+	if remote.S3 != nil {
+		options = append([]Option{WithS3(remote.S3)}, options...)
+	}
+	return url, credentials, timeout, options, nil
+}
+
+func LoadRemotesFileP(fs afero.Fs, path string) map[string]Remote {
+	r, err := LoadRemotesFile(fs, path)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// LoadRemotesFile loads the remotes config, where the environment variables are substituted, i.e:
+//
+//	prod:
+//	  url: sftp://example.com:2222/srv/data
+//	  user: alice
+//	  privateKeyFile: ~/.ssh/id_ed25519
+//	  hostKeyPolicy: tofu
+//	  timeout: 30s
+//	backup:
+//	  url: s3://s3.eu-west-1.amazonaws.com/backup
+//	  user: ${BACKUP_ACCESS_KEY}
+//	  password: ${BACKUP_SECRET_KEY}
+//	  s3:
+//	    region: eu-west-1
+func LoadRemotesFile(fs afero.Fs, path string) (map[string]Remote, error) {
+	m, err := MapFromYamlFile(fs, path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	r := map[string]Remote{}
+	for name, v := range m {
+		remoteMap, err := comm.Map(name, v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "remotes file: %s", path)
+		}
+		remote, err := remoteFromMap(name, remoteMap)
+		if err != nil {
+			return nil, errors.Wrapf(err, "remotes file: %s", path)
+		}
+		r[name] = remote
+	}
+	return r, nil
+}
+
+func remoteFromMap(name string, m map[string]any) (Remote, error) {
+	u, err := comm.RequiredString(name, "url", m)
+	if err != nil {
+		return nil, err
+	}
+	r := &RemoteT{Name: name, Url: u}
+
+	credentials := &CredentialsT{}
+	hasCredentials := false
+	for key, field := range map[string]*string{
+		"user":                 &credentials.User,
+		"password":             &credentials.Password,
+		"privateKey":           &credentials.PrivateKey,
+		"privateKeyFile":       &credentials.PrivateKeyFile,
+		"privateKeyPassphrase": &credentials.PrivateKeyPassphrase,
+		"sessionToken":         &credentials.SessionToken,
+		"knownHostsFile":       &credentials.KnownHostsFile,
+		"hostKeyFingerprint":   &credentials.HostKeyFingerprint,
+		"hostKeyPolicy":        (*string)(&credentials.HostKeyPolicy),
+	} {
+		var has bool
+		if *field, has, err = comm.OptionalString(name, key, m, ""); err != nil {
+			return nil, err
+		}
+		hasCredentials = hasCredentials || has
+	}
+	var has bool
+	if credentials.SshAgent, has, err = comm.OptionalBool(name, "sshAgent", m, false); err != nil {
+		return nil, err
+	}
+	if hasCredentials || has {
+		r.Credentials = credentials
+	}
+
+	if v, has := m["timeout"]; has {
+		if r.Timeout, err = parseRemoteTimeout(name+".timeout", v); err != nil {
+			return nil, err
+		}
+	}
+
+	s3Map, has, err := comm.OptionalMap(name, "s3", m, nil)
+	if err != nil {
+		return nil, err
+	}
+	if has {
+		if r.S3, err = s3ConfigFromMap(name+".s3", s3Map); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// parseRemoteTimeout parses the duration, i.e, "30s", or the seconds
+func parseRemoteTimeout(hint string, v any) (time.Duration, error) {
+	switch t := v.(type) {
+	case int:
+		return time.Duration(t) * time.Second, nil
+	case string:
+		r, err := time.ParseDuration(t)
+		if err != nil {
+			return 0, errors.Wrapf(err, "%s must be a duration, i.e, 30s", hint)
+		}
+		return r, nil
+	}
+	return 0, errors.Errorf("%s must be a duration, i.e, 30s, but now it is %v", hint, v)
+}
+
+func s3ConfigFromMap(hint string, m map[string]any) (S3Config, error) {
+	r := &S3ConfigT{}
+
+	var err error
+	for key, field := range map[string]*string{
+		"endpoint":             &r.Endpoint,
+		"region":               &r.Region,
+		"sessionToken":         &r.SessionToken,
+		"profile":              &r.Profile,
+		"addressingStyle":      (*string)(&r.AddressingStyle),
+		"serverSideEncryption": &r.ServerSideEncryption,
+		"sseKmsKeyId":          &r.SSEKMSKeyId,
+		"sseCustomerKey":       &r.SSECustomerKey,
+	} {
+		if *field, _, err = comm.OptionalString(hint, key, m, ""); err != nil {
+			return nil, err
+		}
+	}
+	if r.Anonymous, _, err = comm.OptionalBool(hint, "anonymous", m, false); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,246 @@
+package ufs
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// PartFileSuffix is appended to the target path for the partial file of resumable download
+const PartFileSuffix = ".part"
+
+// partMetaT records the validators of the remote file, when the partial file was started
+type partMetaT struct {
+	Url     string    `yaml:"url"`
+	ETag    string    `yaml:"etag"`
+	Lastmod time.Time `yaml:"lastmod"`
+	Size    int64     `yaml:"size"`
+}
+
+type partMeta = *partMetaT
+
+func newPartMeta(f File, fi os.FileInfo) partMeta {
+	r := &partMetaT{Url: f.Url(), Lastmod: fi.ModTime().UTC(), Size: fi.Size()}
+	if remoteFi, isRemote := fi.(RemoteFileInfo); isRemote {
+		r.ETag = remoteFi.ETag
+	}
+	return r
+}
+
+// resumable tells if the partial file could be continued with, that is, the remote file is unchanged
+func (me partMeta) resumable(that partMeta) bool {
+	if me.Url != that.Url || me.Size != that.Size || me.Size < 0 {
+		return false
+	}
+	if me.ETag == "" && me.Lastmod.IsZero() {
+		// nothing to tell whether it is changed
+		return false
+	}
+	return me.ETag == that.ETag && me.Lastmod.Equal(that.Lastmod)
+}
+
+func DownloadResumableP(ctx context.Context, f File, fs afero.Fs, path string) int64 {
+	r, err := DownloadResumable(ctx, f, fs, path)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// DownloadResumable downloads the file to the path, through a partial file (path + PartFileSuffix).
+// The validators (ETag, Last-Modified and size) of the remote file are recorded alongside.
+// If the download fails, the partial file is kept, and the next call continues from the end of
+// the partial file with a ranged read, as long as the remote file is unchanged. Otherwise, the
+// download restarts from the beginning. The completed partial file is verified against the checksum
+// and the signature before renamed to the path, and is removed if not matched. Returns the size of
+// the downloaded file.
+func DownloadResumable(ctx context.Context, f File, fs afero.Fs, path string) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	partPath := path + PartFileSuffix
+	metaPath := partPath + ".yaml"
+
+	meta := newPartMeta(f, fi)
+	offset, err := resumableOffset(fs, partPath, metaPath, meta)
+	if err != nil {
+		return 0, err
+	}
+
+	if offset == 0 {
+		metaYaml, err := yaml.Marshal(meta)
+		if err != nil {
+			return 0, errors.Wrap(err, "marshal partial file meta")
+		}
+		if err := Write(fs, metaPath, metaYaml); err != nil {
+			return 0, err
+		}
+	}
+
+	if meta.Size < 0 || offset < meta.Size {
+		src := f
+		if vf, verifiable := f.(verifiableFile); verifiable {
+			// verifies the whole partial file later instead
+			if src, err = vf.unverified(); err != nil {
+				return 0, err
+			}
+		}
+		if err := downloadPart(ctx, src, fs, partPath, offset); err != nil {
+			return 0, err
+		}
+	}
+
+	size, err := checkPartSize(fs, partPath, meta.Size)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := verifyPart(ctx, f, fs, partPath); err != nil {
+		if isChecksumMismatch(err) || isSignatureError(err) {
+			// restarts next time
+			RemoveFile(fs, partPath)
+			RemoveFile(fs, metaPath)
+		}
+		return 0, err
+	}
+
+	if err := RemoveFile(fs, path); err != nil {
+		return 0, err
+	}
+	if err := Rename(fs, partPath, path); err != nil {
+		return 0, err
+	}
+	return size, RemoveFile(fs, metaPath)
+}
+
+// resumableOffset returns the size of partial file if it could be continued with, otherwise 0
+func resumableOffset(fs afero.Fs, partPath string, metaPath string, meta partMeta) (int64, error) {
+	partFi, err := Stat(fs, partPath, false)
+	if err != nil || partFi == nil {
+		return 0, err
+	}
+
+	metaExists, err := FileExists(fs, metaPath)
+	if err != nil || !metaExists {
+		return 0, err
+	}
+
+	previous := &partMetaT{}
+	if err := FromYamlFile(fs, metaPath, false, previous); err != nil {
+		// corrupted meta, just restart
+		return 0, nil
+	}
+
+	if !meta.resumable(previous) || partFi.Size() > meta.Size {
+		return 0, nil
+	}
+	return partFi.Size(), nil
+}
+
+// downloadPart appends to the partial file from the offset, or truncates it if offset is 0
+func downloadPart(ctx context.Context, f File, fs afero.Fs, partPath string, offset int64) error {
+	var src io.ReadCloser
+
+	if offset == 0 {
+		c, err := f.DownloadContext(ctx)
+		if err != nil {
+			return err
+		}
+		src = c.Blob
+	} else {
+		r, err := f.OpenRandom()
+		if err != nil {
+			return err
+		}
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			r.Close()
+			return errors.Wrapf(err, "seek %s to %d", f.Url(), offset)
+		}
+		src = withProgress(r, r.Size(), offset, f.Options().Progress)
+	}
+	defer src.Close()
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if offset == 0 {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	part, err := fs.OpenFile(partPath, flag, 0o640)
+	if err != nil {
+		return errors.Wrapf(err, "open file: %s", partPath)
+	}
+
+	_, err = io.Copy(part, newContextReader(ctx, src))
+	if closeErr := part.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "download %s to %s", f.Url(), partPath)
+	}
+	return nil
+}
+
+// verifiableFile is the file which reads its sibling files, i.e, the checksums file and the signature,
+// and downloads the content without verification
+type verifiableFile interface {
+	File
+	readSibling(ctx context.Context, name string) (string, []byte, error)
+	unverified() (File, error)
+}
+
+// verifyPart verifies the completed partial file against the checksum and the signature, as
+// DownloadContext() does. The partial file is read in streaming, except for the signature which is
+// not prehashed.
+func verifyPart(ctx context.Context, f File, fs afero.Fs, partPath string) error {
+	options := f.Options()
+	if options.Checksum == nil && options.ChecksumsFile == "" && len(options.TrustedKeys) == 0 {
+		return nil
+	}
+
+	readSibling := func(ctx context.Context, name string) (string, []byte, error) {
+		return name, nil, errors.Wrapf(ErrNotSupported, "read %s beside %s", name, f.Url())
+	}
+	if vf, verifiable := f.(verifiableFile); verifiable {
+		readSibling = vf.readSibling
+	}
+
+	checksum, err := checksumOf(ctx, f, readSibling)
+	if err != nil {
+		return errors.Wrapf(err, "verify %s", partPath)
+	}
+
+	part, err := fs.Open(partPath)
+	if err != nil {
+		return errors.Wrapf(err, "open file: %s", partPath)
+	}
+	defer part.Close()
+
+	var content io.Reader = newContextReader(ctx, part)
+	if checksum != nil {
+		content = checksum.newReader(f.Url(), content)
+	}
+
+	if len(options.TrustedKeys) > 0 {
+		_, err = checkSignature(ctx, f, readSibling, content)
+		return err
+	}
+	_, err = io.Copy(io.Discard, content)
+	return err
+}
+
+func checkPartSize(fs afero.Fs, partPath string, expectedSize int64) (int64, error) {
+	fi, err := Stat(fs, partPath, true)
+	if err != nil {
+		return 0, err
+	}
+	if expectedSize >= 0 && fi.Size() != expectedSize {
+		return 0, errors.Errorf("expect %d bytes in %s, but got %d", expectedSize, partPath, fi.Size())
+	}
+	return fi.Size(), nil
+}
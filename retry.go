@@ -0,0 +1,145 @@
+package ufs
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/qiangyt/go-comm/v2"
+)
+
+// RetryPolicyT decides whether and when a failed remote operation is retried
+type RetryPolicyT struct {
+	// total attempts including the first one, 1 or less means no retry
+	MaxAttempts int
+	// backoff before the first retry
+	InitialBackoff time.Duration
+	// upper bound of backoff. If the server asks to retry after longer than it (http 429/503 Retry-After),
+	// the operation is not retried
+	MaxBackoff time.Duration
+	// backoff grows by the multiplier after each retry
+	Multiplier float64
+	// randomly reduces the backoff by up to this fraction (0~1), to avoid retrying in lockstep
+	Jitter float64
+	// classifies the retryable errors. Nil means the protocol's own classifier
+	Retryable func(err error) bool
+}
+
+type RetryPolicy = *RetryPolicyT
+
+// NewRetryPolicy creates a RetryPolicy with exponential backoff, with 3 attempts at most
+func NewRetryPolicy() RetryPolicy {
+	return &RetryPolicyT{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// NoRetry is the RetryPolicy tries exactly once
+var NoRetry RetryPolicy = &RetryPolicyT{MaxAttempts: 1}
+
+var _defaultRetryPolicy atomic.Pointer[RetryPolicyT]
+
+func init() {
+	_defaultRetryPolicy.Store(NewRetryPolicy())
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used when none is specified with WithRetry(). It is
+// NewRetryPolicy() unless changed by SetDefaultRetryPolicy(), so the transient failures are retried
+// with the protocol's own classifier; specify WithRetry(NoRetry) to fall back without delay
+func DefaultRetryPolicy() RetryPolicy {
+	return _defaultRetryPolicy.Load()
+}
+
+// SetDefaultRetryPolicy changes the RetryPolicy used when none is specified with WithRetry().
+// Nil means NoRetry
+func SetDefaultRetryPolicy(policy RetryPolicy) {
+	if policy == nil {
+		policy = NoRetry
+	}
+	_defaultRetryPolicy.Store(policy)
+}
+
+// Backoff returns the delay before the specified retry (1 for the first retry)
+func (me RetryPolicy) Backoff(retry int) time.Duration {
+	r := float64(me.InitialBackoff) * math.Pow(math.Max(me.Multiplier, 1), float64(retry-1))
+	if me.MaxBackoff > 0 && r > float64(me.MaxBackoff) {
+		r = float64(me.MaxBackoff)
+	}
+	if me.Jitter > 0 {
+		r -= r * math.Min(me.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(r)
+}
+
+// Do runs the action, and retries it if fails with retryable error, until succeeded, or the max attempts
+// is reached, or the context is done. what describes the action in the logs, logger could be nil
+func (me RetryPolicy) Do(ctx context.Context, logger comm.Logger, what string, retryable func(err error) bool, action func() error) error {
+	if me.Retryable != nil {
+		retryable = me.Retryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := action()
+		if err == nil || attempt >= me.MaxAttempts || ctx.Err() != nil || retryable == nil || !retryable(err) {
+			return err
+		}
+
+		backoff := me.Backoff(attempt)
+		var statusErr *HttpStatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > backoff {
+			if me.MaxBackoff > 0 && statusErr.RetryAfter > me.MaxBackoff {
+				return err
+			}
+			backoff = statusErr.RetryAfter
+		}
+
+		if logger != nil {
+			logger.Warn().Err(err).Str("what", what).Int("attempt", attempt).Dur("backoff", backoff).Msg("retrying after failure")
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// IsTransientError tells if the error is a network failure which is likely to succeed on retry,
+// i.e, connection reset or refused, timeout, or unexpected EOF. It is the retryable error classifier
+// shared by all protocols
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,225 @@
+package ufs
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
+)
+
+// S3AddressingStyle tells how the bucket is addressed in the request url
+type S3AddressingStyle string
+
+const (
+	// S3AutoStyle is virtual-hosted style for AWS, and path style for the custom endpoints, i.e, MinIO
+	S3AutoStyle S3AddressingStyle = ""
+	// S3PathStyle is https://endpoint/bucket/key
+	S3PathStyle S3AddressingStyle = "path"
+	// S3VirtualHostedStyle is https://bucket.endpoint/key
+	S3VirtualHostedStyle S3AddressingStyle = "virtual-hosted"
+)
+
+// S3DefaultRegion is the region if neither S3ConfigT.Region nor the AWS environment/profile specifies it
+const S3DefaultRegion = "us-east-1"
+
+// S3ConfigT configures the S3 client, see WithS3() and RegisterS3Config()
+type S3ConfigT struct {
+	// the endpoint with scheme, i.e, "http://localhost:9000" for MinIO. By default, the url host is
+	// the endpoint, with https, except for the AWS hosts (*.amazonaws.com) which is resolved by region
+	Endpoint string
+
+	// the region. By default, it is from AWS_REGION or the profile, otherwise S3DefaultRegion
+	Region string
+
+	// the session token of the temporary credentials, along with Credentials.User as the access key
+	// and Credentials.Password as the secret key
+	SessionToken string
+
+	// the profile in the shared config/credentials files (~/.aws/config, ~/.aws/credentials)
+	Profile string
+
+	// sends the requests unsigned, for the public buckets
+	Anonymous bool
+
+	AddressingStyle S3AddressingStyle
+
+	// the server-side encryption of the uploads: "AES256" or "aws:kms"
+	ServerSideEncryption string
+	// the KMS key for "aws:kms", the default KMS key if empty
+	SSEKMSKeyId string
+	// the customer-provided key (SSE-C) of 32 bytes, for both the uploads and the downloads.
+	// The SDK requires https to send it.
+	SSECustomerKey string
+}
+
+type S3Config = *S3ConfigT
+
+// WithS3 specifies the S3 client configuration, which overrides the one registered for the host
+func WithS3(config S3Config) Option {
+	return func(options Options) {
+		options.S3 = config
+	}
+}
+
+var (
+	_s3Configs      = map[string]S3Config{}
+	_s3ConfigsMutex sync.RWMutex
+)
+
+// RegisterS3Config registers the configuration for the S3 urls of the host (without port), nil unregisters it
+func RegisterS3Config(host string, config S3Config) {
+	host = strings.ToLower(host)
+
+	_s3ConfigsMutex.Lock()
+	defer _s3ConfigsMutex.Unlock()
+
+	if config == nil {
+		delete(_s3Configs, host)
+	} else {
+		_s3Configs[host] = config
+	}
+}
+
+// LookupS3Config returns the configuration registered for the host, or nil if not registered
+func LookupS3Config(host string) S3Config {
+	_s3ConfigsMutex.RLock()
+	defer _s3ConfigsMutex.RUnlock()
+
+	return _s3Configs[strings.ToLower(host)]
+}
+
+// resolveS3Config returns the configuration specified by option, otherwise the one registered for the host
+func resolveS3Config(u *url.URL, config S3Config) S3Config {
+	if config != nil {
+		return config
+	}
+	if r := LookupS3Config(u.Hostname()); r != nil {
+		return r
+	}
+	return &S3ConfigT{}
+}
+
+func isAwsHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "amazonaws.com" || strings.HasSuffix(host, ".amazonaws.com")
+}
+
+// newS3Client creates the S3 client for the url host. The static credentials are Credentials.User as
+// the access key and Credentials.Password as the secret key; without them, the credentials come from
+// the default chain: the environment variables, the shared credentials file, then ECS/EC2 (IMDS) roles.
+func newS3Client(u *url.URL, cred Credentials, config S3Config) (*s3.S3, error) {
+	awsConfig := aws.Config{
+		// retries are up to RetryPolicy
+		MaxRetries: aws.Int(0),
+	}
+
+	if config.Region != "" {
+		awsConfig.Region = aws.String(config.Region)
+	}
+
+	awsHost := isAwsHost(u.Hostname())
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+	} else if !awsHost {
+		awsConfig.Endpoint = aws.String(u.Host)
+	}
+
+	switch config.AddressingStyle {
+	case S3AutoStyle:
+		awsConfig.S3ForcePathStyle = aws.Bool(awsConfig.Endpoint != nil && !isAwsHost(hostOfEndpoint(*awsConfig.Endpoint)))
+	case S3PathStyle:
+		awsConfig.S3ForcePathStyle = aws.Bool(true)
+	case S3VirtualHostedStyle:
+		awsConfig.S3ForcePathStyle = aws.Bool(false)
+	default:
+		return nil, errors.Errorf("unknown s3 addressing style: %s", config.AddressingStyle)
+	}
+
+	if config.Anonymous {
+		awsConfig.Credentials = credentials.AnonymousCredentials
+	} else if cred != nil && cred.User != "" {
+		sessionToken := config.SessionToken
+		if sessionToken == "" {
+			sessionToken = cred.SessionToken
+		}
+		awsConfig.Credentials = credentials.NewStaticCredentials(cred.User, cred.Password, sessionToken)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            awsConfig,
+		Profile:           config.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		sess.Config.Region = aws.String(S3DefaultRegion)
+	}
+	return s3.New(sess), nil
+}
+
+// hostOfEndpoint returns the host of the endpoint, which may be without scheme
+func hostOfEndpoint(endpoint string) string {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	return u.Hostname()
+}
+
+func (me S3Config) sseCustomer() (*string, *string) {
+	if me.SSECustomerKey == "" {
+		return nil, nil
+	}
+	return aws.String(s3.ServerSideEncryptionAes256), aws.String(me.SSECustomerKey)
+}
+
+func (me S3Config) sse() (*string, *string) {
+	var encryption, kmsKeyId *string
+	if me.ServerSideEncryption != "" {
+		encryption = aws.String(me.ServerSideEncryption)
+	}
+	if me.SSEKMSKeyId != "" {
+		kmsKeyId = aws.String(me.SSEKMSKeyId)
+	}
+	return encryption, kmsKeyId
+}
+
+func (me S3Config) applyToGet(in *s3.GetObjectInput) *s3.GetObjectInput {
+	in.SSECustomerAlgorithm, in.SSECustomerKey = me.sseCustomer()
+	return in
+}
+
+func (me S3Config) applyToHead(in *s3.HeadObjectInput) *s3.HeadObjectInput {
+	in.SSECustomerAlgorithm, in.SSECustomerKey = me.sseCustomer()
+	return in
+}
+
+func (me S3Config) applyToUpload(in *s3manager.UploadInput) *s3manager.UploadInput {
+	in.ServerSideEncryption, in.SSEKMSKeyId = me.sse()
+	in.SSECustomerAlgorithm, in.SSECustomerKey = me.sseCustomer()
+	return in
+}
+
+func (me S3Config) applyToPut(in *s3.PutObjectInput) *s3.PutObjectInput {
+	in.ServerSideEncryption, in.SSEKMSKeyId = me.sse()
+	in.SSECustomerAlgorithm, in.SSECustomerKey = me.sseCustomer()
+	return in
+}
+
+func (me S3Config) applyToCopy(in *s3.CopyObjectInput) *s3.CopyObjectInput {
+	in.ServerSideEncryption, in.SSEKMSKeyId = me.sse()
+	in.SSECustomerAlgorithm, in.SSECustomerKey = me.sseCustomer()
+	in.CopySourceSSECustomerAlgorithm, in.CopySourceSSECustomerKey = me.sseCustomer()
+	return in
+}
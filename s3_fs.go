@@ -0,0 +1,540 @@
+package ufs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/goodsru/go-universal-network-adapter/services"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// S3FsT is the afero.Fs on top of a S3 bucket, rooted at the key prefix in the url. Directories are
+// the key prefixes delimited by "/", and Mkdir() puts an empty "<dir>/" object as the directory marker.
+// Files are written with multipart uploads, and always replaced as a whole.
+type S3FsT struct {
+	client *s3.S3
+	config S3Config
+	bucket string
+	prefix string
+}
+
+type S3Fs = *S3FsT
+
+var _ afero.Fs = (*S3FsT)(nil)
+
+func NewS3FsP(bucketURL string, credentials Credentials, options ...Option) S3Fs {
+	r, err := NewS3Fs(bucketURL, credentials, options...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// NewS3Fs creates the S3Fs for the bucket url, i.e, s3://host/bucket/prefix. Same as the S3 File,
+// the access key is Credentials.User and the secret key is Credentials.Password, see also WithS3().
+func NewS3Fs(bucketURL string, credentials Credentials, options ...Option) (S3Fs, error) {
+	f, err := NewRemoteFile(bucketURL, credentials, 0, options...)
+	if err != nil {
+		return nil, err
+	}
+	if f.Protocol() != services.S3 {
+		return nil, errors.Errorf("not a s3 url: %s", bucketURL)
+	}
+
+	bucket, prefix, _ := strings.Cut(strings.Trim(f.URL().Path, "/"), "/")
+	if bucket == "" {
+		return nil, errors.Errorf("bucket not specified in url: %s", bucketURL)
+	}
+
+	config := resolveS3Config(f.URL(), f.Options().S3)
+	client, err := newS3Client(f.URL(), f.Credentials(), config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create s3 client: %s", f.Url())
+	}
+	return &S3FsT{client: client, config: config, bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// key returns the object key of the name, empty for the bucket root
+func (me S3Fs) key(name string) string {
+	name = strings.Trim(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if me.prefix == "" {
+		return name
+	}
+	if name == "" {
+		return me.prefix
+	}
+	return me.prefix + "/" + name
+}
+
+// dirPrefix returns the key prefix of the directory entries
+func (me S3Fs) dirPrefix(key string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+func (me S3Fs) normalizeError(err error) error {
+	return (&s3Protocol{}).normalizeError(err)
+}
+
+func (me S3Fs) Name() string {
+	return "S3Fs"
+}
+
+func (me S3Fs) Stat(name string) (os.FileInfo, error) {
+	r, err := me.stat(context.Background(), me.key(name))
+	if err != nil {
+		return nil, newPathError("stat", name, err)
+	}
+	return r, nil
+}
+
+// stat sends HeadObject request for the file, or lists the prefix for the directory
+func (me S3Fs) stat(ctx context.Context, key string) (os.FileInfo, error) {
+	if key != "" {
+		out, err := me.client.HeadObjectWithContext(ctx, me.config.applyToHead(&s3.HeadObjectInput{
+			Bucket: aws.String(me.bucket),
+			Key:    aws.String(key),
+		}))
+		if err == nil {
+			return &RemoteFileInfoT{
+				Filename: path.Base(key),
+				Length:   aws.Int64Value(out.ContentLength),
+				Lastmod:  aws.TimeValue(out.LastModified),
+				ETag:     aws.StringValue(out.ETag),
+			}, nil
+		}
+		if err = me.normalizeError(err); !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	out, err := me.client.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(me.bucket),
+		Prefix:  aws.String(me.dirPrefix(key)),
+		MaxKeys: aws.Int64(1),
+	})
+	if err != nil {
+		return nil, me.normalizeError(err)
+	}
+	if key != "" && len(out.Contents) == 0 && len(out.CommonPrefixes) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return &RemoteFileInfoT{Filename: path.Base("/" + key), Length: 0, Directory: true}, nil
+}
+
+// list returns the entries of the directory
+func (me S3Fs) list(ctx context.Context, key string) ([]os.FileInfo, error) {
+	prefix := me.dirPrefix(key)
+	in := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(me.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	}
+
+	r := []os.FileInfo{}
+	err := me.client.ListObjectsV2PagesWithContext(ctx, in, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, p := range page.CommonPrefixes {
+			r = append(r, &RemoteFileInfoT{
+				Filename:  strings.TrimSuffix(strings.TrimPrefix(aws.StringValue(p.Prefix), prefix), "/"),
+				Directory: true,
+			})
+		}
+		for _, obj := range page.Contents {
+			name := strings.TrimPrefix(aws.StringValue(obj.Key), prefix)
+			if name == "" {
+				// the directory marker
+				continue
+			}
+			r = append(r, &RemoteFileInfoT{
+				Filename: name,
+				Length:   aws.Int64Value(obj.Size),
+				Lastmod:  aws.TimeValue(obj.LastModified),
+				ETag:     aws.StringValue(obj.ETag),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, me.normalizeError(err)
+	}
+	return r, nil
+}
+
+// walk calls the action with every object key under the directory, recursively
+func (me S3Fs) walk(key string, action func(objectKey string) error) error {
+	in := &s3.ListObjectsV2Input{
+		Bucket: aws.String(me.bucket),
+		Prefix: aws.String(me.dirPrefix(key)),
+	}
+
+	var keys []string
+	err := me.client.ListObjectsV2PagesWithContext(context.Background(), in, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		return true
+	})
+	if err != nil {
+		return me.normalizeError(err)
+	}
+
+	for _, k := range keys {
+		if err := action(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (me S3Fs) Create(name string) (afero.File, error) {
+	return me.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (me S3Fs) Open(name string) (afero.File, error) {
+	return me.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the file for either reading or writing. Appending is not supported, and
+// the perm is ignored.
+func (me S3Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	key := me.key(name)
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE) == 0 {
+		return me.openForRead(name, key)
+	}
+
+	if flag&os.O_APPEND != 0 {
+		return nil, newPathError("open", name, ErrNotSupported)
+	}
+	if key == "" {
+		return nil, newPathError("open", name, syscall.EISDIR)
+	}
+
+	if flag&(os.O_CREATE|os.O_EXCL) != os.O_CREATE {
+		fi, err := me.stat(context.Background(), key)
+		if err != nil && !(errors.Is(err, os.ErrNotExist) && flag&os.O_CREATE != 0) {
+			return nil, newPathError("open", name, err)
+		}
+		if fi != nil && flag&os.O_EXCL != 0 {
+			return nil, newPathError("open", name, os.ErrExist)
+		}
+		if fi != nil && fi.IsDir() {
+			return nil, newPathError("open", name, syscall.EISDIR)
+		}
+	}
+
+	uploader := s3manager.NewUploaderWithClient(me.client)
+	writer := newPipeWriter(func(reader io.Reader) error {
+		_, err := uploader.UploadWithContext(context.Background(), me.config.applyToUpload(&s3manager.UploadInput{
+			Bucket: aws.String(me.bucket),
+			Key:    aws.String(key),
+			Body:   reader,
+		}))
+		return err
+	})
+	return &s3WriteFileT{fs: me, name: name, writer: writer}, nil
+}
+
+func (me S3Fs) openForRead(name string, key string) (afero.File, error) {
+	fi, err := me.stat(context.Background(), key)
+	if err != nil {
+		return nil, newPathError("open", name, err)
+	}
+
+	if fi.IsDir() {
+		return newDirFile(name, func() (os.FileInfo, error) {
+			return me.Stat(name)
+		}, func() ([]os.FileInfo, error) {
+			r, err := me.list(context.Background(), key)
+			if err != nil {
+				return nil, newPathError("readdir", name, err)
+			}
+			return r, nil
+		}), nil
+	}
+
+	reader, err := NewRangeReader(fi.Size(), func(offset int64, length int64) (io.ReadCloser, error) {
+		in := me.config.applyToGet(&s3.GetObjectInput{
+			Bucket: aws.String(me.bucket),
+			Key:    aws.String(key),
+			Range:  aws.String(httpRange(offset, length)),
+		})
+		out, err := me.client.GetObjectWithContext(context.Background(), in)
+		if err != nil {
+			return nil, me.normalizeError(err)
+		}
+		return out.Body, nil
+	}, nil)
+	if err != nil {
+		return nil, newPathError("open", name, err)
+	}
+	return &s3ReadFileT{RandomReader: reader, fs: me, name: name}, nil
+}
+
+// putDirMarker puts the empty "<dir>/" object
+func (me S3Fs) putDirMarker(key string) error {
+	_, err := me.client.PutObjectWithContext(context.Background(), me.config.applyToPut(&s3.PutObjectInput{
+		Bucket: aws.String(me.bucket),
+		Key:    aws.String(me.dirPrefix(key)),
+		Body:   bytes.NewReader(nil),
+	}))
+	return me.normalizeError(err)
+}
+
+// Mkdir puts the directory marker. The perm is ignored.
+func (me S3Fs) Mkdir(name string, perm os.FileMode) error {
+	key := me.key(name)
+
+	fi, err := me.stat(context.Background(), key)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		if fi != nil {
+			err = os.ErrExist
+		}
+		return newPathError("mkdir", name, err)
+	}
+	return newPathError("mkdir", name, me.putDirMarker(key))
+}
+
+// MkdirAll puts the directory marker if the directory is not found. The parents are implied by the
+// key prefix, so no markers are put for them. The perm is ignored.
+func (me S3Fs) MkdirAll(name string, perm os.FileMode) error {
+	key := me.key(name)
+
+	fi, err := me.stat(context.Background(), key)
+	if err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		return newPathError("mkdir", name, syscall.ENOTDIR)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return newPathError("mkdir", name, err)
+	}
+	return newPathError("mkdir", name, me.putDirMarker(key))
+}
+
+func (me S3Fs) deleteObject(key string) error {
+	_, err := me.client.DeleteObjectWithContext(context.Background(), &s3.DeleteObjectInput{
+		Bucket: aws.String(me.bucket),
+		Key:    aws.String(key),
+	})
+	return me.normalizeError(err)
+}
+
+// Remove deletes the file, or the directory if it is empty
+func (me S3Fs) Remove(name string) error {
+	key := me.key(name)
+
+	fi, err := me.stat(context.Background(), key)
+	if err != nil {
+		return newPathError("remove", name, err)
+	}
+	if !fi.IsDir() {
+		return newPathError("remove", name, me.deleteObject(key))
+	}
+
+	entries, err := me.list(context.Background(), key)
+	if err != nil {
+		return newPathError("remove", name, err)
+	}
+	if len(entries) > 0 {
+		return newPathError("remove", name, syscall.ENOTEMPTY)
+	}
+	return newPathError("remove", name, me.deleteObject(me.dirPrefix(key)))
+}
+
+// RemoveAll deletes the file, or the directory with everything in it. It is not an error if not found.
+func (me S3Fs) RemoveAll(name string) error {
+	key := me.key(name)
+
+	if key != "" {
+		if err := me.deleteObject(key); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return newPathError("remove", name, err)
+		}
+	}
+	return newPathError("remove", name, me.walk(key, me.deleteObject))
+}
+
+func (me S3Fs) copyObject(fromKey string, toKey string) error {
+	source := (&url.URL{Path: me.bucket + "/" + fromKey}).EscapedPath()
+	_, err := me.client.CopyObjectWithContext(context.Background(), me.config.applyToCopy(&s3.CopyObjectInput{
+		Bucket:     aws.String(me.bucket),
+		Key:        aws.String(toKey),
+		CopySource: aws.String(source),
+	}))
+	return me.normalizeError(err)
+}
+
+// Rename copies then deletes the object, or every object in the directory
+func (me S3Fs) Rename(oldname string, newname string) error {
+	oldKey, newKey := me.key(oldname), me.key(newname)
+
+	fi, err := me.stat(context.Background(), oldKey)
+	if err != nil {
+		return newPathError("rename", oldname, err)
+	}
+
+	if !fi.IsDir() {
+		if err := me.copyObject(oldKey, newKey); err != nil {
+			return newPathError("rename", oldname, err)
+		}
+		return newPathError("rename", oldname, me.deleteObject(oldKey))
+	}
+
+	if oldKey == "" || strings.HasPrefix(newKey+"/", oldKey+"/") {
+		return newPathError("rename", oldname, syscall.EINVAL)
+	}
+
+	oldPrefix, newPrefix := me.dirPrefix(oldKey), me.dirPrefix(newKey)
+	return newPathError("rename", oldname, me.walk(oldKey, func(objectKey string) error {
+		if err := me.copyObject(objectKey, newPrefix+strings.TrimPrefix(objectKey, oldPrefix)); err != nil {
+			return err
+		}
+		return me.deleteObject(objectKey)
+	}))
+}
+
+func (me S3Fs) Chmod(name string, mode os.FileMode) error {
+	return newPathError("chmod", name, ErrNotSupported)
+}
+
+func (me S3Fs) Chown(name string, uid int, gid int) error {
+	return newPathError("chown", name, ErrNotSupported)
+}
+
+func (me S3Fs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return newPathError("chtimes", name, ErrNotSupported)
+}
+
+// s3ReadFileT is the afero.File of an object opened for reading
+type s3ReadFileT struct {
+	RandomReader
+	fs   S3Fs
+	name string
+}
+
+type s3ReadFile = *s3ReadFileT
+
+var _ afero.File = (*s3ReadFileT)(nil)
+
+func (me s3ReadFile) Name() string {
+	return me.name
+}
+
+func (me s3ReadFile) Stat() (os.FileInfo, error) {
+	return me.fs.Stat(me.name)
+}
+
+func (me s3ReadFile) Sync() error {
+	return nil
+}
+
+func (me s3ReadFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, newPathError("readdir", me.name, syscall.ENOTDIR)
+}
+
+func (me s3ReadFile) Readdirnames(n int) ([]string, error) {
+	return nil, newPathError("readdir", me.name, syscall.ENOTDIR)
+}
+
+func (me s3ReadFile) Write(p []byte) (int, error) {
+	return 0, newPathError("write", me.name, syscall.EBADF)
+}
+
+func (me s3ReadFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, newPathError("write", me.name, syscall.EBADF)
+}
+
+func (me s3ReadFile) WriteString(s string) (int, error) {
+	return 0, newPathError("write", me.name, syscall.EBADF)
+}
+
+func (me s3ReadFile) Truncate(size int64) error {
+	return newPathError("truncate", me.name, syscall.EBADF)
+}
+
+// s3WriteFileT is the afero.File of an object opened for writing, the content is uploaded
+// while being written, and Close() completes the upload
+type s3WriteFileT struct {
+	fs     S3Fs
+	name   string
+	writer pipeWriter
+	closed bool
+}
+
+type s3WriteFile = *s3WriteFileT
+
+var _ afero.File = (*s3WriteFileT)(nil)
+
+func (me s3WriteFile) Name() string {
+	return me.name
+}
+
+func (me s3WriteFile) Write(p []byte) (int, error) {
+	if me.closed {
+		return 0, newPathError("write", me.name, os.ErrClosed)
+	}
+	return me.writer.Write(p)
+}
+
+func (me s3WriteFile) WriteString(s string) (int, error) {
+	return me.Write([]byte(s))
+}
+
+func (me s3WriteFile) Close() error {
+	if me.closed {
+		return newPathError("close", me.name, os.ErrClosed)
+	}
+	me.closed = true
+	return newPathError("close", me.name, me.writer.Close())
+}
+
+func (me s3WriteFile) Stat() (os.FileInfo, error) {
+	return me.fs.Stat(me.name)
+}
+
+func (me s3WriteFile) Sync() error {
+	return nil
+}
+
+func (me s3WriteFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, newPathError("readdir", me.name, syscall.ENOTDIR)
+}
+
+func (me s3WriteFile) Readdirnames(n int) ([]string, error) {
+	return nil, newPathError("readdir", me.name, syscall.ENOTDIR)
+}
+
+func (me s3WriteFile) Read(p []byte) (int, error) {
+	return 0, newPathError("read", me.name, syscall.EBADF)
+}
+
+func (me s3WriteFile) ReadAt(p []byte, off int64) (int, error) {
+	return 0, newPathError("read", me.name, syscall.EBADF)
+}
+
+func (me s3WriteFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, newPathError("seek", me.name, ErrNotSupported)
+}
+
+func (me s3WriteFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, newPathError("write", me.name, ErrNotSupported)
+}
+
+func (me s3WriteFile) Truncate(size int64) error {
+	return newPathError("truncate", me.name, ErrNotSupported)
+}
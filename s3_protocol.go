@@ -0,0 +1,210 @@
+package ufs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
+)
+
+type s3Protocol struct{}
+
+// Download gets the object. With Options.TempFile, the object is downloaded in multiple ranges
+// concurrently if it is large.
+func (me *s3Protocol) Download(ctx context.Context, f RemoteFile) (Content, error) {
+	client, config, err := me.dial(f)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, key, err := me.location(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if !f.Options().TempFile {
+		out, err := client.GetObjectWithContext(ctx, config.applyToGet(&s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		}))
+		if err != nil {
+			return nil, me.normalizeError(err)
+		}
+		length := int64(-1)
+		if out.ContentLength != nil {
+			length = *out.ContentLength
+		}
+		return StreamContentOfLength(f, out.Body, length), nil
+	}
+
+	downloader := s3manager.NewDownloaderWithClient(client)
+
+	return DownloadToTempFile(f, func(tmp *os.File) error {
+		_, err := downloader.DownloadWithContext(ctx, tmp, config.applyToGet(&s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		}))
+		return me.normalizeError(err)
+	})
+}
+
+// Upload puts the object, in multiple parts if the content is large
+func (me *s3Protocol) Upload(ctx context.Context, f RemoteFile, reader io.Reader) (int64, error) {
+	client, config, err := me.dial(f)
+	if err != nil {
+		return 0, err
+	}
+
+	bucket, key, err := me.location(f)
+	if err != nil {
+		return 0, err
+	}
+	counter := newCountingReader(reader)
+
+	uploader := s3manager.NewUploaderWithClient(client)
+	_, err = uploader.UploadWithContext(ctx, config.applyToUpload(&s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   counter,
+	}))
+	return counter.Count(), err
+}
+
+// Stat sends HeadObject request for the object, or lists the key prefix for the directory
+func (me *s3Protocol) Stat(ctx context.Context, f RemoteFile) (os.FileInfo, error) {
+	fs, key, err := me.bucketFs(f)
+	if err != nil {
+		return nil, err
+	}
+	return fs.stat(ctx, key)
+}
+
+// List lists the directory, i.e, the objects and the common prefixes under "<key>/", page by page
+func (me *s3Protocol) List(ctx context.Context, f RemoteFile) ([]os.FileInfo, error) {
+	fs, key, err := me.bucketFs(f)
+	if err != nil {
+		return nil, err
+	}
+	return fs.list(ctx, key)
+}
+
+// Remove sends DeleteObject request
+func (me *s3Protocol) Remove(ctx context.Context, f RemoteFile) error {
+	client, _, err := me.dial(f)
+	if err != nil {
+		return err
+	}
+
+	bucket, key, err := me.location(f)
+	if err != nil {
+		return err
+	}
+	_, err = client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return me.normalizeError(err)
+}
+
+// OpenRandom reads with ranged GetObject requests
+func (me *s3Protocol) OpenRandom(ctx context.Context, f RemoteFile) (RandomReader, error) {
+	fi, err := me.Stat(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	client, config, err := me.dial(f)
+	if err != nil {
+		return nil, err
+	}
+	bucket, key, err := me.location(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRangeReader(fi.Size(), func(offset int64, length int64) (io.ReadCloser, error) {
+		in := config.applyToGet(&s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Range:  aws.String(httpRange(offset, length)),
+		})
+
+		out, err := client.GetObjectWithContext(ctx, in)
+		if err != nil {
+			return nil, me.normalizeError(err)
+		}
+		return out.Body, nil
+	}, nil)
+}
+
+// normalizeError translates 404 to os.ErrNotExist
+func (me *s3Protocol) normalizeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if reqErr, isReqErr := err.(awserr.RequestFailure); isReqErr && reqErr.StatusCode() == http.StatusNotFound {
+		return os.ErrNotExist
+	}
+	return err
+}
+
+// Retryable retries on throttling (i.e, SlowDown), 429, 5xx, and the transient network failures
+func (me *s3Protocol) Retryable(err error) bool {
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+	if reqErr, isReqErr := err.(awserr.RequestFailure); isReqErr {
+		code := reqErr.StatusCode()
+		if code == http.StatusTooManyRequests || (code >= 500 && code != http.StatusNotImplemented) {
+			return true
+		}
+	}
+	if awsErr, isAwsErr := err.(awserr.Error); isAwsErr {
+		switch awsErr.Code() {
+		case "RequestTimeout", "InternalError", "ServiceUnavailable":
+			return true
+		}
+		if awsErr.OrigErr() != nil {
+			return IsTransientError(awsErr.OrigErr())
+		}
+		return false
+	}
+	return IsTransientError(err)
+}
+
+// location returns the bucket and the object key: the first segment of url path is the bucket, the rest is the key
+func (me *s3Protocol) location(f RemoteFile) (string, string, error) {
+	bucket, key, _ := strings.Cut(strings.Trim(f.URL().Path, "/"), "/")
+	if bucket == "" {
+		return "", "", errors.Errorf("bucket not specified in url: %s", f.Url())
+	}
+	return bucket, key, nil
+}
+
+// bucketFs returns the S3Fs of the bucket, and the object key
+func (me *s3Protocol) bucketFs(f RemoteFile) (S3Fs, string, error) {
+	bucket, key, err := me.location(f)
+	if err != nil {
+		return nil, "", err
+	}
+	client, config, err := me.dial(f)
+	if err != nil {
+		return nil, "", err
+	}
+	return &S3FsT{client: client, config: config, bucket: bucket}, key, nil
+}
+
+// dial creates the client with the configuration resolved for the file
+func (me *s3Protocol) dial(f RemoteFile) (*s3.S3, S3Config, error) {
+	config := resolveS3Config(f.URL(), f.Options().S3)
+	client, err := newS3Client(f.URL(), f.Credentials(), config)
+	return client, config, err
+}
@@ -0,0 +1,269 @@
+package ufs
+
+import (
+	"crypto/ed25519"
+	"crypto/md5"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultKnownHostsFile is the known_hosts file if Credentials.KnownHostsFile is not specified
+const DefaultKnownHostsFile = "~/.ssh/known_hosts"
+
+// knownHostsMutex serializes the appending of trust-on-first-use host keys
+var knownHostsMutex sync.Mutex
+
+// sshAuth returns the auth methods by the credentials, in the order of public keys (ssh-agent and
+// the private key), password, and keyboard-interactive. The returned close func closes the
+// ssh-agent connection, which is needed only during the handshake.
+func sshAuth(credentials Credentials) ([]ssh.AuthMethod, func(), error) {
+	var signers []ssh.Signer
+	closeAgent := func() {}
+
+	if credentials.SshAgent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, nil, errors.New("ssh-agent is enabled but SSH_AUTH_SOCK is not set")
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "connect to ssh-agent: %s", sock)
+		}
+		closeAgent = func() { conn.Close() }
+
+		agentSigners, err := agent.NewClient(conn).Signers()
+		if err != nil {
+			closeAgent()
+			return nil, nil, errors.Wrap(err, "list ssh-agent keys")
+		}
+		signers = append(signers, agentSigners...)
+	}
+
+	signer, err := sshPrivateKey(credentials)
+	if err != nil {
+		closeAgent()
+		return nil, nil, err
+	}
+	if signer != nil {
+		signers = append(signers, signer)
+	}
+
+	var r []ssh.AuthMethod
+	if len(signers) > 0 {
+		r = append(r, ssh.PublicKeys(signers...))
+	}
+	if credentials.Password != "" {
+		r = append(r, ssh.Password(credentials.Password))
+	}
+	if credentials.KeyboardInteractive != nil {
+		r = append(r, ssh.KeyboardInteractive(credentials.KeyboardInteractive))
+	} else if credentials.Password != "" {
+		r = append(r, ssh.KeyboardInteractive(passwordChallenge(credentials.Password)))
+	}
+	return r, closeAgent, nil
+}
+
+// passwordChallenge answers every keyboard-interactive question with the password
+func passwordChallenge(password string) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		r := make([]string, len(questions))
+		for i := range r {
+			r[i] = password
+		}
+		return r, nil
+	}
+}
+
+// sshPrivateKey parses PrivateKey, or PrivateKeyFile, or the deprecated RsaPrivateKey, whichever
+// is specified first; nil if none
+func sshPrivateKey(credentials Credentials) (ssh.Signer, error) {
+	key, passphrase := credentials.PrivateKey, credentials.PrivateKeyPassphrase
+	if key == "" && credentials.PrivateKeyFile != "" {
+		path, err := ExpandHomePath(credentials.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read private key file: %s", path)
+		}
+		key = string(bytes)
+	}
+	if key == "" {
+		key, passphrase = credentials.RsaPrivateKey, credentials.RsaPrivateKeyPassphrase
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	var r ssh.Signer
+	var err error
+	if passphrase == "" {
+		r, err = ssh.ParsePrivateKey([]byte(key))
+	} else {
+		r, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parse private key")
+	}
+	return r, nil
+}
+
+// sshHostKeyCallback verifies the host key by the pinned fingerprint, otherwise by the known_hosts
+// file. It also returns the host key algorithms to negotiate, which are those of the known keys of
+// the host, so that the server does not present another kind of key than the known one.
+func sshHostKeyCallback(credentials Credentials, addr string) (ssh.HostKeyCallback, []string, error) {
+	if fingerprint := credentials.HostKeyFingerprint; fingerprint != "" {
+		return pinnedHostKey(fingerprint), nil, nil
+	}
+
+	switch credentials.HostKeyPolicy {
+	case HostKeyInsecure:
+		return ssh.InsecureIgnoreHostKey(), nil, nil
+	case HostKeyStrict, HostKeyTrustOnFirstUse:
+	default:
+		return nil, nil, errors.Errorf("unknown host key policy: %s", credentials.HostKeyPolicy)
+	}
+
+	path := credentials.KnownHostsFile
+	if path == "" {
+		path = DefaultKnownHostsFile
+	}
+	path, err := ExpandHomePath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	knownHostsMutex.Lock()
+	defer knownHostsMutex.Unlock()
+
+	callback, err := loadKnownHosts(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	tofu := credentials.HostKeyPolicy == HostKeyTrustOnFirstUse
+
+	r := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			if err != nil {
+				return errors.Wrapf(err, "verify host key of %s", hostname)
+			}
+			return nil
+		}
+		if len(keyErr.Want) > 0 {
+			return errors.Errorf("host key of %s does not match %s:%d, the %s key %s is presented",
+				hostname, keyErr.Want[0].Filename, keyErr.Want[0].Line, key.Type(), ssh.FingerprintSHA256(key))
+		}
+		if !tofu {
+			return errors.Errorf("host key of %s is unknown, the %s key %s is not in %s",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), path)
+		}
+		return appendKnownHost(path, hostname, key)
+	}
+	return r, knownHostKeyAlgorithms(callback, addr), nil
+}
+
+// loadKnownHosts loads the known_hosts file, the missing file is treated as empty
+func loadKnownHosts(path string) (ssh.HostKeyCallback, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return knownhosts.New()
+	}
+
+	r, err := knownhosts.New(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load known_hosts: %s", path)
+	}
+	return r, nil
+}
+
+// appendKnownHost appends the host key to the known_hosts file, creating it if not exists
+func appendKnownHost(path string, hostname string, key ssh.PublicKey) error {
+	knownHostsMutex.Lock()
+	defer knownHostsMutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Wrapf(err, "create directory of known_hosts: %s", path)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrapf(err, "open known_hosts: %s", path)
+	}
+	_, err = f.WriteString(knownhosts.Line([]string{hostname}, key) + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return errors.Wrapf(err, "append to known_hosts: %s", path)
+}
+
+// knownHostKeyAlgorithms returns the algorithms of the known keys of the host, nil if unknown.
+// knownhosts does not tell the keys directly, so it is probed with a key which never matches.
+func knownHostKeyAlgorithms(callback ssh.HostKeyCallback, addr string) []string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	remote := &net.TCPAddr{IP: net.ParseIP(host)}
+	if remote.IP == nil {
+		remote.IP = net.IPv6zero
+	}
+	remote.Port, _ = strconv.Atoi(port)
+
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(callback(addr, remote, probe), &keyErr) {
+		return nil
+	}
+
+	var r []string
+	for _, known := range keyErr.Want {
+		switch keyType := known.Key.Type(); keyType {
+		case ssh.KeyAlgoRSA:
+			r = append(r, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			r = append(r, keyType)
+		}
+	}
+	return r
+}
+
+// pinnedHostKey accepts only the host key of the fingerprint, in "SHA256:..." or the legacy MD5
+// "xx:xx:..." format, with or without the "MD5:" prefix
+func pinnedHostKey(fingerprint string) ssh.HostKeyCallback {
+	sha256 := strings.HasPrefix(fingerprint, "SHA256:")
+	if !sha256 {
+		fingerprint = strings.ReplaceAll(strings.TrimPrefix(strings.ToLower(fingerprint), "md5:"), ":", "")
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		var actual string
+		if sha256 {
+			actual = ssh.FingerprintSHA256(key)
+		} else {
+			sum := md5.Sum(key.Marshal())
+			actual = hex.EncodeToString(sum[:])
+		}
+
+		if actual != fingerprint {
+			return errors.Errorf("host key of %s does not match the pinned fingerprint, the %s key %s is presented",
+				hostname, key.Type(), ssh.FingerprintSHA256(key))
+		}
+		return nil
+	}
+}
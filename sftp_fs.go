@@ -0,0 +1,372 @@
+package ufs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/services"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/spf13/afero"
+)
+
+// SftpFsT is the afero.Fs on top of a sftp server, rooted at the url path. One connection is shared
+// by all operations and opened files, and it is established again on demand once lost or closed.
+type SftpFsT struct {
+	file RemoteFile
+	root string
+
+	mutex  sync.Mutex
+	client sftpClient
+}
+
+type SftpFs = *SftpFsT
+
+var _ afero.Fs = (*SftpFsT)(nil)
+
+func NewSftpFsP(url string, credentials Credentials, timeout time.Duration, options ...Option) SftpFs {
+	r, err := NewSftpFs(url, credentials, timeout, options...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// NewSftpFs connects to the sftp server of the url. The paths are relative to the url path,
+// or relative to the login directory if the url has no path. Same as the sftp File, the credentials
+// is resolved by Options.CredentialsProvider if nil, and the connecting is retried by Options.Retry.
+// The permission of the created files and directories is masked by Options.Umask, see WithUmask().
+func NewSftpFs(url string, credentials Credentials, timeout time.Duration, options ...Option) (SftpFs, error) {
+	f, err := NewRemoteFile(url, credentials, timeout, options...)
+	if err != nil {
+		return nil, err
+	}
+	if f.Protocol() != services.SFTP {
+		return nil, errors.Errorf("not a sftp url: %s", url)
+	}
+
+	r := &SftpFsT{file: f, root: f.URL().Path}
+	if _, err := r.sftp(); err != nil {
+		return nil, errors.Wrapf(err, "connect %s", f.Url())
+	}
+	return r, nil
+}
+
+// sftp returns the shared connection, connects if not yet connected
+func (me SftpFs) sftp() (*sftp.Client, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	if me.client != nil {
+		return me.client.Client, nil
+	}
+
+	ctx, p := context.Background(), &sftpProtocol{}
+	var client sftpClient
+	err := me.file.retry(ctx, p, "connect", func() (err error) {
+		client, err = p.dial(ctx, me.file)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	me.client = client
+
+	go func() {
+		// forgets the connection once lost, so that the next operation connects again
+		client.Wait()
+		me.drop(client.Client)
+	}()
+
+	return client.Client, nil
+}
+
+// drop closes the connection if it is still the shared one
+func (me SftpFs) drop(client *sftp.Client) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	if me.client != nil && me.client.Client == client {
+		me.client.Close()
+		me.client = nil
+	}
+}
+
+// Close closes the shared connection. The SftpFs is still usable, it connects again on demand.
+func (me SftpFs) Close() error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	if me.client == nil {
+		return nil
+	}
+	err := me.client.Close()
+	me.client = nil
+	return err
+}
+
+// do runs the action with the shared connection. If the connection turns out to be lost,
+// the action runs again once with a new connection.
+func (me SftpFs) do(action func(client *sftp.Client) error) error {
+	for attempt := 0; ; attempt++ {
+		client, err := me.sftp()
+		if err != nil {
+			return err
+		}
+
+		err = action(client)
+		if attempt > 0 || !(errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, sftp.ErrSSHFxNoConnection)) {
+			return err
+		}
+		me.drop(client)
+	}
+}
+
+func (me SftpFs) path(name string) string {
+	name = filepath.ToSlash(name)
+	if me.root == "" {
+		return name
+	}
+	return path.Join(me.root, name)
+}
+
+func (me SftpFs) Name() string {
+	return "SftpFs"
+}
+
+func (me SftpFs) Create(name string) (afero.File, error) {
+	return me.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (me SftpFs) Open(name string) (afero.File, error) {
+	return me.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the remote file. The perm, masked by Options.Umask, applies only if the file is created.
+func (me SftpFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	p := me.path(name)
+
+	var r afero.File
+	err := me.do(func(client *sftp.Client) error {
+		if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+			// directories are not opened by sftp, but listed
+			fi, err := client.Stat(p)
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				r = newDirFile(name, func() (os.FileInfo, error) {
+					return me.Stat(name)
+				}, func() ([]os.FileInfo, error) {
+					var entries []os.FileInfo
+					err := me.do(func(client *sftp.Client) (err error) {
+						entries, err = client.ReadDir(p)
+						return err
+					})
+					return entries, newPathError("readdir", name, err)
+				})
+				return nil
+			}
+		}
+
+		created := false
+		if flag&os.O_CREATE != 0 {
+			_, err := client.Lstat(p)
+			created = errors.Is(err, os.ErrNotExist)
+		}
+
+		f, err := client.OpenFile(p, flag)
+		if err != nil {
+			return err
+		}
+		if created {
+			if err := client.Chmod(p, me.file.Options().Perm(perm)); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if flag&os.O_APPEND != 0 {
+			if _, err := f.Seek(0, io.SeekEnd); err != nil {
+				f.Close()
+				return err
+			}
+		}
+
+		r = &sftpFileT{File: f, name: name}
+		return nil
+	})
+	if err != nil {
+		return nil, newPathError("open", name, err)
+	}
+	return r, nil
+}
+
+func (me SftpFs) Mkdir(name string, perm os.FileMode) error {
+	p := me.path(name)
+	return newPathError("mkdir", name, me.do(func(client *sftp.Client) error {
+		if err := client.Mkdir(p); err != nil {
+			return err
+		}
+		return client.Chmod(p, me.file.Options().Perm(perm))
+	}))
+}
+
+func (me SftpFs) MkdirAll(name string, perm os.FileMode) error {
+	p := me.path(name)
+	return newPathError("mkdir", name, me.do(func(client *sftp.Client) error {
+		return me.mkdirAll(client, p, perm)
+	}))
+}
+
+func (me SftpFs) mkdirAll(client *sftp.Client, p string, perm os.FileMode) error {
+	fi, err := client.Stat(p)
+	if err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		return syscall.ENOTDIR
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if parent := path.Dir(p); parent != p && parent != "." && parent != "/" {
+		if err := me.mkdirAll(client, parent, perm); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mkdir(p); err != nil {
+		// created by someone else meanwhile
+		if fi, statErr := client.Stat(p); statErr == nil && fi.IsDir() {
+			return nil
+		}
+		return err
+	}
+	return client.Chmod(p, me.file.Options().Perm(perm))
+}
+
+// Remove deletes the file, or the directory if it is empty
+func (me SftpFs) Remove(name string) error {
+	p := me.path(name)
+	return newPathError("remove", name, me.do(func(client *sftp.Client) error {
+		fi, err := client.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return client.RemoveDirectory(p)
+		}
+		return client.Remove(p)
+	}))
+}
+
+// RemoveAll deletes the file, or the directory with everything in it. It is not an error if not found.
+func (me SftpFs) RemoveAll(name string) error {
+	p := me.path(name)
+	return newPathError("remove", name, me.do(func(client *sftp.Client) error {
+		return me.removeAll(client, p)
+	}))
+}
+
+func (me SftpFs) removeAll(client *sftp.Client, p string) error {
+	fi, err := client.Lstat(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if !fi.IsDir() {
+		return client.Remove(p)
+	}
+
+	children, err := client.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := me.removeAll(client, path.Join(p, child.Name())); err != nil {
+			return err
+		}
+	}
+	return client.RemoveDirectory(p)
+}
+
+// Rename moves the file, replacing the existing new file if the server supports posix-rename
+func (me SftpFs) Rename(oldname string, newname string) error {
+	oldPath, newPath := me.path(oldname), me.path(newname)
+	return newPathError("rename", oldname, me.do(func(client *sftp.Client) error {
+		if _, posix := client.HasExtension("posix-rename@openssh.com"); posix {
+			return client.PosixRename(oldPath, newPath)
+		}
+		return client.Rename(oldPath, newPath)
+	}))
+}
+
+func (me SftpFs) Stat(name string) (os.FileInfo, error) {
+	p := me.path(name)
+
+	var r os.FileInfo
+	err := me.do(func(client *sftp.Client) (err error) {
+		r, err = client.Stat(p)
+		return err
+	})
+	if err != nil {
+		return nil, newPathError("stat", name, err)
+	}
+	return r, nil
+}
+
+func (me SftpFs) Chmod(name string, mode os.FileMode) error {
+	p := me.path(name)
+	return newPathError("chmod", name, me.do(func(client *sftp.Client) error {
+		return client.Chmod(p, mode)
+	}))
+}
+
+func (me SftpFs) Chown(name string, uid int, gid int) error {
+	p := me.path(name)
+	return newPathError("chown", name, me.do(func(client *sftp.Client) error {
+		return client.Chown(p, uid, gid)
+	}))
+}
+
+func (me SftpFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	p := me.path(name)
+	return newPathError("chtimes", name, me.do(func(client *sftp.Client) error {
+		return client.Chtimes(p, atime, mtime)
+	}))
+}
+
+// sftpFileT is the afero.File of a regular remote file
+type sftpFileT struct {
+	*sftp.File
+	name string
+}
+
+type sftpFile = *sftpFileT
+
+var _ afero.File = (*sftpFileT)(nil)
+
+func (me sftpFile) Name() string {
+	return me.name
+}
+
+func (me sftpFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: me.name, Err: syscall.ENOTDIR}
+}
+
+func (me sftpFile) Readdirnames(n int) ([]string, error) {
+	return nil, &os.PathError{Op: "readdir", Path: me.name, Err: syscall.ENOTDIR}
+}
+
+func (me sftpFile) WriteString(s string) (int, error) {
+	return me.Write([]byte(s))
+}
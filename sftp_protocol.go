@@ -0,0 +1,280 @@
+package ufs
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+type sftpProtocol struct{}
+
+// sftpClientT closes the underlying ssh connection together with the sftp session,
+// and the ssh connection is closed as well once the context is done
+type sftpClientT struct {
+	*sftp.Client
+	sshClient *ssh.Client
+	stopWatch func() bool
+}
+
+type sftpClient = *sftpClientT
+
+func (me sftpClient) Close() error {
+	me.stopWatch()
+
+	err := me.Client.Close()
+	if sshErr := me.sshClient.Close(); err == nil {
+		err = sshErr
+	}
+	return err
+}
+
+func (me *sftpProtocol) Download(ctx context.Context, f RemoteFile) (Content, error) {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := client.Open(f.URL().Path)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	if !f.Options().TempFile {
+		return StreamContent(f, newReadCloser(newContextReader(ctx, remote), func() error {
+			err := remote.Close()
+			if clientErr := client.Close(); err == nil {
+				err = clientErr
+			}
+			return err
+		})), nil
+	}
+
+	defer client.Close()
+	defer remote.Close()
+	return DownloadToTempFile(f, func(tmp *os.File) error {
+		_, err := io.Copy(tmp, newContextReader(ctx, remote))
+		return me.contextError(ctx, err)
+	})
+}
+
+func (me *sftpProtocol) Upload(ctx context.Context, f RemoteFile, reader io.Reader) (int64, error) {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	remote, err := client.Create(f.URL().Path)
+	if err != nil {
+		return 0, err
+	}
+
+	r, err := io.Copy(remote, reader)
+	if closeErr := remote.Close(); err == nil {
+		err = closeErr
+	}
+	return r, err
+}
+
+func (me *sftpProtocol) Stat(ctx context.Context, f RemoteFile) (os.FileInfo, error) {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	fi, err := client.Stat(f.URL().Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoteFileInfoT{
+		Filename:  fi.Name(),
+		Length:    fi.Size(),
+		Lastmod:   fi.ModTime(),
+		Directory: fi.IsDir(),
+	}, nil
+}
+
+func (me *sftpProtocol) List(ctx context.Context, f RemoteFile) ([]os.FileInfo, error) {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	entries, err := client.ReadDir(f.URL().Path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		r = append(r, &RemoteFileInfoT{
+			Filename:  entry.Name(),
+			Length:    entry.Size(),
+			Lastmod:   entry.ModTime(),
+			Directory: entry.IsDir(),
+		})
+	}
+	return r, nil
+}
+
+func (me *sftpProtocol) Remove(ctx context.Context, f RemoteFile) error {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	path := f.URL().Path
+	fi, err := client.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return client.RemoveDirectory(path)
+	}
+	return client.Remove(path)
+}
+
+// contextError prefers the context error, because the transfer error is just a consequence of the cancellation
+func (me *sftpProtocol) contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// OpenRandom keeps the remote file open, ReadAt() and Seek() are served by the sftp file directly
+func (me *sftpProtocol) OpenRandom(ctx context.Context, f RemoteFile) (RandomReader, error) {
+	client, err := me.dial(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := client.Open(f.URL().Path)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	fi, err := remote.Stat()
+	if err != nil {
+		remote.Close()
+		client.Close()
+		return nil, err
+	}
+
+	return &sftpRandomReaderT{File: remote, size: fi.Size(), client: client}, nil
+}
+
+type sftpRandomReaderT struct {
+	*sftp.File
+	size   int64
+	client sftpClient
+}
+
+func (me *sftpRandomReaderT) Size() int64 {
+	return me.size
+}
+
+func (me *sftpRandomReaderT) Close() error {
+	err := me.File.Close()
+	if clientErr := me.client.Close(); err == nil {
+		err = clientErr
+	}
+	return err
+}
+
+// Retryable retries on lost connection, and the transient network failures
+func (me *sftpProtocol) Retryable(err error) bool {
+	if errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, sftp.ErrSSHFxNoConnection) {
+		return true
+	}
+	return IsTransientError(err)
+}
+
+// dial connects by the auth methods and the host key verification configured in Credentials
+func (me *sftpProtocol) dial(ctx context.Context, f RemoteFile) (sftpClient, error) {
+	credentials := f.Credentials()
+
+	addr := f.URL().Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	hostKeyCallback, hostKeyAlgorithms, err := sshHostKeyCallback(credentials, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, closeAgent, err := sshAuth(credentials)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	config := &ssh.ClientConfig{
+		User:              credentials.User,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		Timeout:           f.Timeout(),
+	}
+
+	sshClient, err := me.sshDial(ctx, addr, config)
+	if err != nil {
+		return nil, me.contextError(ctx, err)
+	}
+
+	stopWatch := context.AfterFunc(ctx, func() { sshClient.Close() })
+	r, err := sftp.NewClient(sshClient)
+	if err != nil {
+		stopWatch()
+		sshClient.Close()
+		return nil, me.contextError(ctx, err)
+	}
+	return &sftpClientT{Client: r, sshClient: sshClient, stopWatch: stopWatch}, nil
+}
+
+// sshDial is ssh.Dial with a deadline on the handshake as well, because the timeout
+// in ssh.ClientConfig only applies to establishment of the tcp connection.
+// The connection is closed if the context is done during the handshake.
+func (me *sftpProtocol) sshDial(ctx context.Context, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	stopWatch := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stopWatch()
+
+	if config.Timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(config.Timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
@@ -0,0 +1,256 @@
+package ufs
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+// SignatureSuffix is appended to the file name for the detached signature
+const SignatureSuffix = ".sig"
+
+const (
+	minisignUntrustedComment = "untrusted comment:"
+	minisignTrustedComment   = "trusted comment:"
+	minisignKeyIdSize        = 8
+)
+
+var (
+	// the signature algorithm of minisign: ed25519 of the content, or of the BLAKE2b-512 hash of the content
+	minisignPureAlgorithm   = []byte("Ed")
+	minisignHashedAlgorithm = []byte("ED")
+	minisignPublicKeySize   = len(minisignPureAlgorithm) + minisignKeyIdSize + ed25519.PublicKeySize
+	minisignSignatureSize   = len(minisignPureAlgorithm) + minisignKeyIdSize + ed25519.SignatureSize
+	minisignKeyIdStart      = len(minisignPureAlgorithm)
+)
+
+// SignatureKeyT is a trusted ed25519 public key of the detached signatures
+type SignatureKeyT struct {
+	// the key id of minisign public key, nil for the raw ed25519 public key
+	Id  []byte
+	Key ed25519.PublicKey
+}
+
+type SignatureKey = *SignatureKeyT
+
+// SignatureError is the error of the content which is unsigned, or not signed by any trusted key
+type SignatureError struct {
+	Url    string
+	Reason string
+
+	// the cause if the signature could not be read, could be nil
+	Err error
+}
+
+func (me *SignatureError) Error() string {
+	if me.Err != nil {
+		return fmt.Sprintf("verify signature of %s: %s: %v", me.Url, me.Reason, me.Err)
+	}
+	return fmt.Sprintf("verify signature of %s: %s", me.Url, me.Reason)
+}
+
+func (me *SignatureError) Unwrap() error {
+	return me.Err
+}
+
+// isSignatureError tells if the error is caused by SignatureError
+func isSignatureError(err error) bool {
+	var signatureErr *SignatureError
+	return errors.As(err, &signatureErr)
+}
+
+func ParseSignatureKeyP(text string) SignatureKey {
+	r, err := ParseSignatureKey(text)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// ParseSignatureKey parses the minisign public key, either the content of the .pub file or the base64
+// line of it, or the raw ed25519 public key in base64 or hex
+func ParseSignatureKey(text string) (SignatureKey, error) {
+	line := ""
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, minisignUntrustedComment) {
+			line = l
+			break
+		}
+	}
+
+	if b, err := base64.StdEncoding.DecodeString(line); err == nil {
+		if len(b) == minisignPublicKeySize && bytes.Equal(b[:len(minisignPureAlgorithm)], minisignPureAlgorithm) {
+			return &SignatureKeyT{
+				Id:  b[minisignKeyIdStart : minisignKeyIdStart+minisignKeyIdSize],
+				Key: ed25519.PublicKey(b[minisignKeyIdStart+minisignKeyIdSize:]),
+			}, nil
+		}
+		if len(b) == ed25519.PublicKeySize {
+			return &SignatureKeyT{Key: ed25519.PublicKey(b)}, nil
+		}
+	}
+	if b, err := hex.DecodeString(line); err == nil && len(b) == ed25519.PublicKeySize {
+		return &SignatureKeyT{Key: ed25519.PublicKey(b)}, nil
+	}
+	return nil, errors.New("not a minisign or ed25519 public key")
+}
+
+// keyIdText formats the key id as minisign prints
+func keyIdText(id []byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(id))
+}
+
+// signedBlobT is the content verified against the detached signature, which keeps the signature
+// for the fallback file, see DownloadBytesContext()
+type signedBlobT struct {
+	io.Reader
+	signature []byte
+}
+
+func (me *signedBlobT) Close() error {
+	return nil
+}
+
+// signatureOf returns the signature which the blob is verified against, nil if not verified
+func signatureOf(blob io.Reader) []byte {
+	if signed, isSigned := blob.(*signedBlobT); isSigned {
+		return signed.signature
+	}
+	return nil
+}
+
+// verifySignature reads the whole content and verifies it against the detached signature read by
+// readSibling, before the content is returned. It returns blob as-is if Options.TrustedKeys is empty.
+func verifySignature(ctx context.Context, f File, readSibling readSiblingFunc, blob io.ReadCloser) (io.ReadCloser, error) {
+	if len(f.Options().TrustedKeys) == 0 {
+		return blob, nil
+	}
+	defer blob.Close()
+
+	var content bytes.Buffer
+	sig, err := checkSignature(ctx, f, readSibling, io.TeeReader(newContextReader(ctx, blob), &content))
+	if err != nil {
+		return nil, err
+	}
+	return &signedBlobT{Reader: &content, signature: sig}, nil
+}
+
+// checkSignature reads the detached signature by readSibling, then verifies the content against it
+// with Options.TrustedKeys, and returns the signature if verified. The content is hashed in streaming
+// for the prehashed minisign signature, otherwise it is read into memory.
+func checkSignature(ctx context.Context, f File, readSibling readSiblingFunc, content io.Reader) ([]byte, error) {
+	sigUrl, sig, err := readSibling(ctx, f.Name()+SignatureSuffix)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, &SignatureError{Url: f.Url(), Reason: "unsigned, no signature " + sigUrl}
+		}
+		return nil, &SignatureError{Url: f.Url(), Reason: "read signature " + sigUrl, Err: err}
+	}
+
+	reason, err := verifyDetached(f.Options().TrustedKeys, content, sig)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return nil, &SignatureError{Url: f.Url(), Reason: reason}
+	}
+	return sig, nil
+}
+
+// verifyDetached verifies the content against the minisign signature, or the raw ed25519 signature
+// in binary, base64 or hex. It returns the reason if failed, otherwise empty.
+func verifyDetached(keys []SignatureKey, content io.Reader, sig []byte) (string, error) {
+	text := strings.TrimSpace(string(sig))
+	if strings.HasPrefix(text, minisignUntrustedComment) {
+		return verifyMinisign(keys, content, text)
+	}
+
+	raw := sig
+	switch len(text) {
+	case base64.StdEncoding.EncodedLen(ed25519.SignatureSize):
+		raw, _ = base64.StdEncoding.DecodeString(text)
+	case hex.EncodedLen(ed25519.SignatureSize):
+		raw, _ = hex.DecodeString(text)
+	}
+	if len(raw) != ed25519.SignatureSize {
+		return "malformed signature", nil
+	}
+
+	message, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key.Key, message, raw) {
+			return "", nil
+		}
+	}
+	return "not signed by any trusted key", nil
+}
+
+// verifyMinisign verifies the minisign signature, which is:
+//
+//	untrusted comment: <comment>
+//	base64(<algorithm "Ed" or "ED"> <key id> <ed25519 signature>)
+//	trusted comment: <comment>
+//	base64(<ed25519 signature of the signature and the trusted comment>)
+func verifyMinisign(keys []SignatureKey, content io.Reader, text string) (string, error) {
+	lines := strings.Split(text, "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], minisignTrustedComment) {
+		return "malformed minisign signature", nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != minisignSignatureSize {
+		return "malformed minisign signature", nil
+	}
+	algorithm := sig[:minisignKeyIdStart]
+	id := sig[minisignKeyIdStart : minisignKeyIdStart+minisignKeyIdSize]
+	sig = sig[minisignKeyIdStart+minisignKeyIdSize:]
+
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return "malformed minisign signature", nil
+	}
+	trustedComment := strings.TrimSuffix(strings.TrimPrefix(lines[2], minisignTrustedComment+" "), "\r")
+
+	var message []byte
+	switch {
+	case bytes.Equal(algorithm, minisignHashedAlgorithm):
+		hash, _ := blake2b.New512(nil)
+		if _, err := io.Copy(hash, content); err != nil {
+			return "", err
+		}
+		message = hash.Sum(nil)
+	case bytes.Equal(algorithm, minisignPureAlgorithm):
+		if message, err = io.ReadAll(content); err != nil {
+			return "", err
+		}
+	default:
+		return fmt.Sprintf("unsupported minisign algorithm: %q", algorithm), nil
+	}
+
+	for _, key := range keys {
+		if key.Id != nil && !bytes.Equal(key.Id, id) {
+			continue
+		}
+		if !ed25519.Verify(key.Key, message, sig) {
+			continue
+		}
+		if !ed25519.Verify(key.Key, append(append([]byte{}, sig...), trustedComment...), globalSig) {
+			return "bad signature of the trusted comment", nil
+		}
+		return "", nil
+	}
+	return "not signed by any trusted key, key id " + keyIdText(id), nil
+}
@@ -3,7 +3,9 @@ package test
 import (
 	"io"
 	"net/url"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -57,3 +59,84 @@ func Test_AferoFile_happy(t *testing.T) {
 	defer c2.Blob.Close()
 	a.Equal("hi", string(txt2))
 }
+
+func Test_AferoFile_Upload(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	ufs.WriteTextP(fs, "/test.txt", "old content")
+
+	f := ufs.NewAferoFileP(fs, "/test.txt", nil, 0)
+	n := f.UploadP(strings.NewReader("new"))
+	a.Equal(int64(3), n)
+	a.Equal("new", ufs.ReadTextP(fs, "/test.txt"))
+
+	w := f.CreateP()
+	_, err := io.WriteString(w, "created")
+	a.NoError(err)
+	a.NoError(w.Close())
+	a.Equal("created", ufs.ReadTextP(fs, "/test.txt"))
+}
+
+func Test_AferoFile_Stat(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	f := ufs.NewAferoFileP(fs, "/test.txt", nil, 0)
+	a.False(f.ExistsP())
+	_, err := f.Stat()
+	a.True(os.IsNotExist(err))
+
+	ufs.WriteTextP(fs, "/test.txt", "hello")
+	a.True(f.ExistsP())
+	a.Equal(int64(5), f.StatP().Size())
+}
+
+func Test_AferoFile_List(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	ufs.WriteTextP(fs, "/dir/a.txt", "a")
+	ufs.WriteTextP(fs, "/dir/sub/b.txt", "b")
+
+	entries := ufs.NewAferoFileP(fs, "/dir", nil, 0).ListP()
+	a.Len(entries, 2)
+	a.Equal("a.txt", entries[0].Name())
+	a.Equal("sub", entries[1].Name())
+	a.True(entries[1].IsDir())
+
+	entries = ufs.ListURLP(fs, "file:///dir/sub", nil, 0)
+	a.Len(entries, 1)
+	a.Equal("b.txt", entries[0].Name())
+
+	_, err := ufs.ListURL(fs, "/not-found", nil, 0)
+	a.Error(err)
+}
+
+func Test_AferoFile_Remove(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	ufs.WriteTextP(fs, "/dir/a.txt", "a")
+
+	ufs.NewAferoFileP(fs, "/dir/a.txt", nil, 0).RemoveP()
+	a.False(ufs.FileExistsP(fs, "/dir/a.txt"))
+
+	ufs.NewAferoFileP(fs, "/dir/a.txt", nil, 0).RemoveP()
+}
+
+func Test_AferoFile_OpenRandom(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	ufs.WriteTextP(fs, "/test.bin", "0123456789")
+
+	r := ufs.NewAferoFileP(fs, "/test.bin", nil, 0).OpenRandomP()
+	defer r.Close()
+	a.Equal(int64(10), r.Size())
+
+	p := make([]byte, 2)
+	_, err := r.ReadAt(p, 4)
+	a.NoError(err)
+	a.Equal("45", string(p))
+}
@@ -0,0 +1,197 @@
+package test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func sha256Hex(text string) string {
+	d := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(d[:])
+}
+
+func Test_WithChecksum(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	// the afero blob removes the file once closed
+	download := func(options ...ufs.Option) (string, error) {
+		ufs.WriteTextP(fs, "/a.txt", "hello")
+		return ufs.DownloadText(nil, "", fs, "/a.txt", nil, 0, options...)
+	}
+
+	sha512Sum := sha512.Sum512([]byte("hello"))
+	md5Sum := md5.Sum([]byte("hello"))
+	for _, option := range []ufs.Option{
+		ufs.WithChecksum(ufs.ChecksumSHA256, sha256Hex("hello")),
+		ufs.WithChecksum(ufs.ChecksumSHA512, hex.EncodeToString(sha512Sum[:])),
+		ufs.WithChecksum("MD5", strings.ToUpper(hex.EncodeToString(md5Sum[:]))),
+	} {
+		actual, err := download(option)
+		a.NoError(err)
+		a.Equal("hello", actual)
+	}
+
+	_, err := download(ufs.WithChecksum(ufs.ChecksumSHA256, sha256Hex("bye")))
+	var mismatchErr *ufs.ChecksumMismatchError
+	a.True(errors.As(err, &mismatchErr))
+	a.Equal(ufs.ChecksumSHA256, mismatchErr.Algorithm)
+	a.Equal(sha256Hex("bye"), mismatchErr.Expected)
+	a.Equal(sha256Hex("hello"), mismatchErr.Actual)
+
+	_, err = download(ufs.WithChecksum("crc32", "3610a686"))
+	a.ErrorContains(err, "unsupported checksum algorithm")
+
+	_, err = download(ufs.WithChecksum(ufs.ChecksumSHA256, "abc"))
+	a.ErrorContains(err, "invalid sha256 checksum")
+}
+
+func Test_Checksum_streaming(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+	ufs.WriteTextP(fs, "/a.txt", "hello")
+
+	f := ufs.NewFileP(fs, "/a.txt", nil, 0, ufs.WithChecksum(ufs.ChecksumSHA256, sha256Hex("bye")))
+	c := f.DownloadP()
+	defer c.Blob.Close()
+
+	// the content is passed through, and the mismatch is reported at the end
+	buf := make([]byte, 3)
+	n, err := c.Blob.Read(buf)
+	a.NoError(err)
+	a.Equal("hel", string(buf[:n]))
+
+	_, err = io.ReadAll(c.Blob)
+	a.Error(err)
+	_, err = c.Blob.Read(buf)
+	a.Error(err)
+}
+
+func Test_Checksum_urlFragment(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Empty(r.URL.Fragment)
+		io.WriteString(w, "hello "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	f := ufs.NewFileP(nil, server.URL+"/a.txt#sha256="+sha256Hex("hello /a.txt"), nil, 0)
+	a.Equal(server.URL+"/a.txt", f.Url())
+	a.Equal(sha256Hex("hello /a.txt"), f.Options().Checksum.Digest)
+
+	a.Equal("hello /a.txt", ufs.DownloadTextP(nil, "", nil, server.URL+"/a.txt#SHA256="+sha256Hex("hello /a.txt"), nil, 0))
+
+	_, err := ufs.DownloadText(nil, "", nil, server.URL+"/a.txt#sha256="+sha256Hex("hello"), nil, 0)
+	a.ErrorContains(err, "sha256 checksum mismatch")
+
+	// the other fragments are kept as is
+	f = ufs.NewFileP(nil, server.URL+"/a.txt#top", nil, 0)
+	a.Nil(f.Options().Checksum)
+}
+
+func Test_Checksum_checksumsFile(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+	ufs.WriteTextP(fs, "/dist/SHA256SUMS", "# generated\n"+
+		sha256Hex("hello")+"  a.txt\n"+
+		sha256Hex("bye")+" *./b.txt\n"+
+		sha256Hex("original")+"  c.txt\n")
+	ufs.WriteTextP(fs, "/dist/CHECKSUMS", "SHA256 (a.txt) = "+sha256Hex("hello")+"\n")
+
+	// the afero blob removes the file once closed
+	download := func(name string, content string, options ...ufs.Option) (string, error) {
+		ufs.WriteTextP(fs, "/dist/"+name, content)
+		return ufs.DownloadText(nil, "", fs, "/dist/"+name, nil, 0, options...)
+	}
+
+	actual, err := download("a.txt", "hello", ufs.WithChecksumsFile("SHA256SUMS"))
+	a.NoError(err)
+	a.Equal("hello", actual)
+	actual, err = download("b.txt", "bye", ufs.WithChecksumsFile("SHA256SUMS"))
+	a.NoError(err)
+	a.Equal("bye", actual)
+	actual, err = download("a.txt", "hello", ufs.WithChecksumsFile("CHECKSUMS"))
+	a.NoError(err)
+	a.Equal("hello", actual)
+
+	_, err = download("c.txt", "tampered", ufs.WithChecksumsFile("SHA256SUMS"))
+	a.ErrorContains(err, "checksum mismatch")
+
+	_, err = download("b.txt", "bye", ufs.WithChecksumsFile("CHECKSUMS"))
+	a.ErrorContains(err, "no checksum of b.txt")
+
+	_, err = download("a.txt", "hello", ufs.WithChecksumsFile("SHA512SUMS"))
+	a.ErrorContains(err, "read checksums file")
+
+	// the explicit checksum takes precedence
+	_, err = download("a.txt", "hello", ufs.WithChecksumsFile("SHA256SUMS"), ufs.WithChecksum(ufs.ChecksumSHA256, sha256Hex("bye")))
+	a.ErrorContains(err, "checksum mismatch")
+}
+
+func Test_Checksum_remoteChecksumsFile(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dist/SHA256SUMS" {
+			a.Empty(r.URL.RawQuery)
+			io.WriteString(w, sha256Hex("hello /dist/a.txt")+"  a.txt\n")
+			return
+		}
+		io.WriteString(w, "hello "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	a.Equal("hello /dist/a.txt", ufs.DownloadTextP(nil, "", nil, server.URL+"/dist/a.txt?v=1", nil, 0, ufs.WithChecksumsFile("SHA256SUMS")))
+}
+
+func Test_Checksum_fallback(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	content := "hello"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if content == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, content)
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+	fallbackDir := "/fallback"
+	url := server.URL + "/a.txt"
+	noRetry := ufs.WithRetry(ufs.NoRetry)
+
+	a.Equal("hello", ufs.DownloadTextP(nil, fallbackDir, fs, url, nil, 0, ufs.WithChecksum(ufs.ChecksumSHA256, sha256Hex("hello"))))
+	a.True(ufs.HasFallbackFile(fallbackDir, fs, url))
+
+	// the tampered content neither replaces the fallback file, nor falls back to it
+	content = "tampered"
+	_, err := ufs.DownloadText(nil, fallbackDir, fs, url, nil, 0, ufs.WithChecksum(ufs.ChecksumSHA256, sha256Hex("hello")))
+	a.ErrorContains(err, "checksum mismatch")
+	_, fallback, err := ufs.ReadFallbackFile(fallbackDir, fs, url)
+	a.NoError(err)
+	a.Equal("hello", string(fallback))
+
+	// the fallback file is used if matched
+	content = ""
+	a.Equal("hello", ufs.DownloadTextP(nil, fallbackDir, fs, url, nil, 0, noRetry, ufs.WithChecksum(ufs.ChecksumSHA256, sha256Hex("hello"))))
+	_, err = ufs.DownloadText(nil, fallbackDir, fs, url, nil, 0, noRetry, ufs.WithChecksum(ufs.ChecksumSHA256, sha256Hex("updated")))
+	a.Error(err)
+}
@@ -0,0 +1,219 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func resolveCredentials(t *testing.T, provider ufs.CredentialsProvider, rawUrl string) ufs.Credentials {
+	u, err := url.Parse(rawUrl)
+	require.NoError(t, err)
+	r, err := provider.Credentials(u)
+	require.NoError(t, err)
+	return r
+}
+
+// isolateCredentials points HOME and the credentials files to an empty directory, so that the tests do
+// not pick up the real credentials of the files built with nil credentials
+func isolateCredentials(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("NETRC", filepath.Join(home, ".netrc"))
+	t.Setenv(ufs.CredentialsFileEnv, filepath.Join(home, "credentials"))
+	t.Setenv(ufs.CredentialsPassphraseEnv, "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(home, "aws-credentials"))
+}
+
+func Test_NetrcCredentialsProvider(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+	ufs.WriteTextP(fs, "/netrc", `# comment
+machine example.com login alice password secret1
+machine other.com
+  login bob
+  password secret2
+macdef init
+  machine evil.com login mallory password x
+
+default login anonymous password guest
+`)
+	provider := ufs.NetrcCredentialsProvider(fs, "/netrc")
+
+	cred := resolveCredentials(t, provider, "https://example.com:8443/a.txt")
+	a.Equal("alice", cred.User)
+	a.Equal("secret1", cred.Password)
+
+	cred = resolveCredentials(t, provider, "ftp://OTHER.com/a.txt")
+	a.Equal("bob", cred.User)
+	a.Equal("secret2", cred.Password)
+
+	cred = resolveCredentials(t, provider, "ftp://evil.com/a.txt")
+	a.Equal("anonymous", cred.User)
+	a.Equal("guest", cred.Password)
+
+	a.Nil(resolveCredentials(t, ufs.NetrcCredentialsProvider(fs, "/missing"), "https://example.com/"))
+
+	// the parsed netrc is cached until the file is modified
+	cred.User = "changed"
+	a.Equal("anonymous", resolveCredentials(t, provider, "ftp://evil.com/a.txt").User)
+
+	ufs.WriteTextP(fs, "/netrc", "machine example.com login carol password secret3\n")
+	a.NoError(fs.Chtimes("/netrc", time.Now(), time.Now().Add(time.Hour)))
+	a.Equal("carol", resolveCredentials(t, provider, "https://example.com/").User)
+	a.Nil(resolveCredentials(t, provider, "ftp://evil.com/a.txt"))
+}
+
+func Test_NetrcCredentialsProvider_env(t *testing.T) {
+	a := require.New(t)
+	path := filepath.Join(t.TempDir(), "netrc")
+	ufs.WriteTextP(afero.NewOsFs(), path, "machine example.com login alice password secret\n")
+	t.Setenv("NETRC", path)
+
+	cred := resolveCredentials(t, ufs.NetrcCredentialsProvider(afero.NewOsFs(), ""), "https://example.com/")
+	a.Equal("alice", cred.User)
+}
+
+func Test_EnvCredentialsProvider(t *testing.T) {
+	a := require.New(t)
+	t.Setenv("UFS_EXAMPLE_COM_USER", "alice")
+	t.Setenv("UFS_EXAMPLE_COM_PASSWORD", "secret")
+	t.Setenv("UFS_EXAMPLE_COM_2222_USER", "bob")
+	t.Setenv("UFS_EXAMPLE_COM_2222_PRIVATE_KEY_FILE", "~/.ssh/id_ed25519")
+	provider := ufs.EnvCredentialsProvider()
+
+	cred := resolveCredentials(t, provider, "https://example.com/a.txt")
+	a.Equal("alice", cred.User)
+	a.Equal("secret", cred.Password)
+
+	cred = resolveCredentials(t, provider, "sftp://example.com:2222/a.txt")
+	a.Equal("bob", cred.User)
+	a.Equal("~/.ssh/id_ed25519", cred.PrivateKeyFile)
+
+	cred = resolveCredentials(t, provider, "https://example.com:8443/a.txt")
+	a.Equal("alice", cred.User)
+
+	a.Nil(resolveCredentials(t, provider, "https://other.com/a.txt"))
+}
+
+func Test_CredentialsFile(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	entries := []ufs.CredentialsEntryT{
+		{Url: "https://example.com", User: "alice", Password: "secret1"},
+		{Url: "https://example.com/api/", User: "bob", Password: "secret2"},
+		{Url: "//example.com:2222", User: "carol", HostKeyFingerprint: "SHA256:abc"},
+	}
+	ufs.WriteCredentialsFileP(fs, "/ufs/credentials", "passphrase", entries)
+
+	content := ufs.ReadTextP(fs, "/ufs/credentials")
+	a.NotContains(content, "secret1")
+	a.NotContains(content, "alice")
+
+	a.Equal(entries, ufs.ReadCredentialsFileP(fs, "/ufs/credentials", "passphrase"))
+
+	_, err := ufs.ReadCredentialsFile(fs, "/ufs/credentials", "wrong")
+	a.ErrorContains(err, "wrong passphrase")
+
+	ufs.WriteTextP(fs, "/plain", "url: https://example.com")
+	_, err = ufs.ReadCredentialsFile(fs, "/plain", "passphrase")
+	a.ErrorContains(err, "not a credentials file")
+}
+
+func Test_CredentialsFileProvider(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+	ufs.WriteCredentialsFileP(fs, "/ufs/credentials", "passphrase", []ufs.CredentialsEntryT{
+		{Url: "https://example.com", User: "alice"},
+		{Url: "https://example.com/api/", User: "bob"},
+		{Url: "//example.com:2222", User: "carol", HostKeyFingerprint: "SHA256:abc"},
+	})
+	provider := ufs.CredentialsFileProvider(fs, "/ufs/credentials", "passphrase")
+
+	a.Equal("alice", resolveCredentials(t, provider, "https://example.com/a.txt").User)
+	a.Equal("alice", resolveCredentials(t, provider, "https://example.com/apis/a.txt").User)
+	a.Equal("bob", resolveCredentials(t, provider, "https://example.com/api/v1/a.txt").User)
+	a.Equal("bob", resolveCredentials(t, provider, "https://example.com/api").User)
+
+	cred := resolveCredentials(t, provider, "sftp://example.com:2222/a.txt")
+	a.Equal("carol", cred.User)
+	a.Equal("SHA256:abc", cred.HostKeyFingerprint)
+
+	a.Nil(resolveCredentials(t, provider, "http://example.com/a.txt"))
+	a.Nil(resolveCredentials(t, provider, "https://other.com/a.txt"))
+
+	// without passphrase, the file is not consulted
+	t.Setenv(ufs.CredentialsPassphraseEnv, "")
+	a.Nil(resolveCredentials(t, ufs.CredentialsFileProvider(fs, "/ufs/credentials", ""), "https://example.com/a.txt"))
+
+	t.Setenv(ufs.CredentialsPassphraseEnv, "wrong")
+	_, err := ufs.CredentialsFileProvider(fs, "/ufs/credentials", "").Credentials(&url.URL{Scheme: "https", Host: "example.com"})
+	a.ErrorContains(err, "wrong passphrase")
+}
+
+func Test_CredentialsProviderChain(t *testing.T) {
+	a := require.New(t)
+
+	calls := 0
+	provider := func(user string) ufs.CredentialsProvider {
+		return ufs.CredentialsProviderFunc(func(u *url.URL) (ufs.Credentials, error) {
+			calls++
+			if u.Host == user+".com" {
+				return &ufs.CredentialsT{User: user}, nil
+			}
+			return nil, nil
+		})
+	}
+	chain := ufs.CredentialsProviderChain(provider("alice"), provider("bob"))
+
+	a.Equal("alice", resolveCredentials(t, chain, "https://alice.com/").User)
+	a.Equal(1, calls)
+	a.Equal("bob", resolveCredentials(t, chain, "https://bob.com/").User)
+	a.Equal(3, calls)
+	a.Nil(resolveCredentials(t, chain, "https://carol.com/"))
+}
+
+func Test_NewFile_resolveCredentials(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "alice" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "hello "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	ufs.WriteTextP(afero.NewOsFs(), netrc, "machine 127.0.0.1 login alice password secret\n")
+	t.Setenv("NETRC", netrc)
+
+	f := ufs.NewFileP(nil, server.URL+"/a.txt", nil, 3*time.Second)
+	a.Equal("alice", f.Credentials().User)
+	a.Equal("hello /a.txt", ufs.DownloadTextP(nil, "", nil, server.URL+"/a.txt", nil, 3*time.Second))
+
+	// the specified credentials are used as is
+	_, err := ufs.DownloadText(nil, "", nil, server.URL+"/a.txt", &ufs.CredentialsT{User: "alice"}, 3*time.Second)
+	a.Error(err)
+
+	// the environment variables go before netrc
+	host := strings.ToUpper(strings.NewReplacer(".", "_", ":", "_").Replace(strings.TrimPrefix(server.URL, "http://")))
+	t.Setenv("UFS_"+host+"_USER", "bob")
+	a.Equal("bob", ufs.NewFileP(nil, server.URL+"/a.txt", nil, 3*time.Second).Credentials().User)
+
+	// the resolving is disabled by the empty chain
+	f = ufs.NewFileP(nil, server.URL+"/a.txt", nil, 3*time.Second, ufs.WithCredentialsProvider(ufs.CredentialsProviderChain()))
+	a.Empty(f.Credentials().User)
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+// startDavServer starts the in-process WebDAV server with basic auth, and returns its dav:// url
+func startDavServer(t *testing.T) string {
+	handler := &webdav.Handler{FileSystem: webdav.NewMemFS(), LockSystem: webdav.NewMemLS()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "u" || password != "p" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return ufs.DAV + strings.TrimPrefix(server.URL, "http://")
+}
+
+func newDavFile(url string) ufs.File {
+	return ufs.NewFileP(nil, url, &ufs.CredentialsT{User: "u", Password: "p"}, 3*time.Second)
+}
+
+func Test_DavProtocol_happy(t *testing.T) {
+	a := require.New(t)
+	url := startDavServer(t)
+	a.True(ufs.IsRemote(url + "/x.txt"))
+
+	newDavFile(url + "/docs").MkdirP()
+	f := newDavFile(url + "/docs/hello.txt")
+	a.False(f.ExistsP())
+
+	a.Equal(int64(9), f.UploadP(strings.NewReader("hello dav")))
+	a.True(f.ExistsP())
+
+	fi := f.StatP()
+	a.Equal("hello.txt", fi.Name())
+	a.Equal(int64(9), fi.Size())
+	a.False(fi.IsDir())
+	a.NotEmpty(fi.(ufs.RemoteFileInfo).ETag)
+	a.False(fi.ModTime().IsZero())
+
+	c := f.DownloadP()
+	b, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+	a.NoError(c.Blob.Close())
+	a.Equal("hello dav", string(b))
+
+	r := f.OpenRandomP()
+	buf := make([]byte, 3)
+	_, err = r.ReadAt(buf, 6)
+	a.NoError(err)
+	a.Equal("dav", string(buf))
+	a.NoError(r.Close())
+
+	a.Equal("hello dav", ufs.DownloadTextP(nil, "", nil, url+"/docs/hello.txt", &ufs.CredentialsT{User: "u", Password: "p"}, 3*time.Second))
+}
+
+func Test_DavProtocol_dirs(t *testing.T) {
+	a := require.New(t)
+	url := startDavServer(t)
+
+	dir := newDavFile(url + "/docs/")
+	dir.MkdirP()
+	a.True(dir.StatP().IsDir())
+	a.ErrorIs(dir.Mkdir(), os.ErrExist)
+	a.ErrorIs(newDavFile(url+"/missing/sub").Mkdir(), os.ErrNotExist)
+
+	newDavFile(url + "/docs/sub").MkdirP()
+	newDavFile(url + "/docs/a b.txt").UploadP(strings.NewReader("a"))
+
+	var names []string
+	for _, entry := range newDavFile(url + "/docs").ListP() {
+		names = append(names, entry.Name())
+		a.Equal(entry.Name() == "sub", entry.IsDir())
+	}
+	sort.Strings(names)
+	a.Equal([]string{"a b.txt", "sub"}, names)
+
+	a.ErrorIs(dir.Remove(), syscall.ENOTEMPTY)
+	newDavFile(url + "/docs/a b.txt").RemoveP()
+	newDavFile(url + "/docs/sub").RemoveP()
+	dir.RemoveP()
+	a.False(dir.ExistsP())
+
+	// not an error if not found
+	dir.RemoveP()
+}
+
+func Test_DavProtocol_unauthorized(t *testing.T) {
+	a := require.New(t)
+	url := startDavServer(t)
+
+	_, err := ufs.NewFileP(nil, url+"/x.txt", &ufs.CredentialsT{User: "u", Password: "wrong"}, 3*time.Second).Stat()
+	a.ErrorContains(err, "401")
+}
+
+func Test_AferoFile_Mkdir(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	ufs.NewFileP(fs, "/dir", nil, 0).MkdirP()
+	a.True(ufs.DirExistsP(fs, "/dir"))
+}
@@ -10,6 +10,9 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// the mock is regenerated once the File interface changes
+var _ ufs.File = (*MockFile)(nil)
+
 func Test_WorkDir_happy(t *testing.T) {
 	a := require.New(t)
 
@@ -92,6 +95,7 @@ func Test_DownloadText_happy(t *testing.T) {
 
 func Test_DownloadText_Remote(t *testing.T) {
 	a := require.New(t)
+	isolateCredentials(t)
 	afs := afero.NewMemMapFs()
 
 	fallbackDir := "/fallback"
@@ -0,0 +1,213 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func newFtpFile(t *testing.T, name string) (ufs.File, string) {
+	server := startFtpServer(t, "tester", "secret", false)
+	f := ufs.NewFileP(nil, server.Url(name), server.Credentials("secret"), 5*time.Second)
+	return f, server.Path(name)
+}
+
+func Test_FtpProtocol_Upload(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newFtpFile(t, "Test_FtpProtocol_Upload.txt")
+
+	n, err := f.Upload(strings.NewReader("hello ftp"))
+	a.NoError(err)
+	a.Equal(int64(len("hello ftp")), n)
+
+	a.Equal("hello ftp", ufs.ReadTextP(afero.NewOsFs(), localPath))
+}
+
+func Test_FtpProtocol_Create(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newFtpFile(t, "Test_FtpProtocol_Create.txt")
+
+	w := f.CreateP()
+	_, err := w.Write([]byte("hello "))
+	a.NoError(err)
+	_, err = w.Write([]byte("world"))
+	a.NoError(err)
+	a.NoError(w.Close())
+
+	a.Equal("hello world", ufs.ReadTextP(afero.NewOsFs(), localPath))
+}
+
+func Test_FtpProtocol_WrongPassword(t *testing.T) {
+	a := require.New(t)
+	server := startFtpServer(t, "tester", "secret", false)
+
+	f := ufs.NewFileP(nil, server.Url("x.txt"), server.Credentials("wrong"), 5*time.Second, ufs.WithRetry(ufs.NoRetry))
+	_, err := f.Upload(strings.NewReader("x"))
+	a.Error(err)
+}
+
+func Test_FtpProtocol_Stat(t *testing.T) {
+	a := require.New(t)
+	server := startFtpServer(t, "tester", "secret", false)
+	cred := server.Credentials("secret")
+
+	f := ufs.NewFileP(nil, server.Url("a.txt"), cred, 5*time.Second)
+	a.False(f.ExistsP())
+	_, err := f.Stat()
+	a.True(os.IsNotExist(err))
+
+	fs := afero.NewOsFs()
+	ufs.WriteTextP(fs, server.Path("a.txt"), "12345")
+	lastmod := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	a.NoError(os.Chtimes(server.Path("a.txt"), lastmod, lastmod))
+	a.True(f.ExistsP())
+
+	fi := f.StatP()
+	a.Equal("a.txt", fi.Name())
+	a.Equal(int64(5), fi.Size())
+	a.False(fi.IsDir())
+	a.True(lastmod.Equal(fi.ModTime()))
+
+	ufs.MkdirP(fs, server.Path("sub"))
+	fi = ufs.NewFileP(nil, server.Url("sub"), cred, 5*time.Second).StatP()
+	a.True(fi.IsDir())
+}
+
+func Test_FtpProtocol_List(t *testing.T) {
+	a := require.New(t)
+	server := startFtpServer(t, "tester", "secret", false)
+
+	fs := afero.NewOsFs()
+	ufs.MkdirP(fs, server.Path("sub"))
+	ufs.WriteTextP(fs, server.Path("a.yaml"), "a")
+
+	// the test server lists the working directory only, so lists the root
+	entries := ufs.ListURLP(nil, server.Url(""), server.Credentials("secret"), 5*time.Second)
+	a.Len(entries, 2)
+
+	byName := map[string]os.FileInfo{}
+	for _, entry := range entries {
+		byName[entry.Name()] = entry
+	}
+	a.False(byName["a.yaml"].IsDir())
+	a.Equal(int64(1), byName["a.yaml"].Size())
+	a.True(byName["sub"].IsDir())
+}
+
+func Test_FtpProtocol_Remove(t *testing.T) {
+	a := require.New(t)
+	server := startFtpServer(t, "tester", "secret", false)
+	cred := server.Credentials("secret")
+
+	fs := afero.NewOsFs()
+	ufs.WriteTextP(fs, server.Path("a.txt"), "x")
+	ufs.MkdirP(fs, server.Path("sub"))
+
+	// DELE for the file
+	ufs.NewFileP(nil, server.Url("a.txt"), cred, 5*time.Second).RemoveP()
+	a.False(ufs.FileExistsP(fs, server.Path("a.txt")))
+
+	// RMD for the directory
+	ufs.NewFileP(nil, server.Url("sub"), cred, 5*time.Second).RemoveP()
+	a.False(ufs.DirExistsP(fs, server.Path("sub")))
+
+	// not an error if not found
+	a.NoError(ufs.NewFileP(nil, server.Url("a.txt"), cred, 5*time.Second).Remove())
+}
+
+func Test_FtpProtocol_Download(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newFtpFile(t, "Test_FtpProtocol_Download.txt")
+
+	ufs.WriteTextP(afero.NewOsFs(), localPath, "hello ftp")
+
+	actual := ufs.DownloadTextP(nil, "", nil, f.Url(), f.Credentials(), 5*time.Second)
+	a.Equal("hello ftp", actual)
+}
+
+func Test_FtpProtocol_Download_tempFile(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newFtpFile(t, "Test_FtpProtocol_Download_tempFile.txt")
+
+	ufs.WriteTextP(afero.NewOsFs(), localPath, "hello ftp")
+
+	streaming := ufs.NewFileP(nil, f.Url(), f.Credentials(), 5*time.Second).DownloadP()
+	txt, err := io.ReadAll(streaming.Blob)
+	a.NoError(err)
+	a.NoError(streaming.Blob.Close())
+	a.Equal("hello ftp", string(txt))
+
+	buffered := ufs.NewFileP(nil, f.Url(), f.Credentials(), 5*time.Second, ufs.WithTempFile()).DownloadP()
+	a.NotEqual("/Test_FtpProtocol_Download_tempFile.txt", buffered.Path)
+	txt, err = io.ReadAll(buffered.Blob)
+	a.NoError(err)
+	a.NoError(buffered.Blob.Close())
+	a.Equal("hello ftp", string(txt))
+}
+
+func Test_FtpProtocol_DownloadContext_canceled(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newFtpFile(t, "Test_FtpProtocol_DownloadContext_canceled.txt")
+
+	ufs.WriteTextP(afero.NewOsFs(), localPath, "hello ftp")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := f.DownloadContext(ctx)
+	a.ErrorIs(err, context.Canceled)
+}
+
+func Test_FtpProtocol_OpenRandom(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newFtpFile(t, "Test_FtpProtocol_OpenRandom.bin")
+
+	ufs.WriteTextP(afero.NewOsFs(), localPath, "0123456789")
+
+	r := f.OpenRandomP()
+	defer r.Close()
+	a.Equal(int64(10), r.Size())
+
+	p := make([]byte, 3)
+	_, err := r.ReadAt(p, 5)
+	a.NoError(err)
+	a.Equal("567", string(p))
+
+	// one range at a time on the control connection
+	_, err = r.ReadAt(p, 1)
+	a.NoError(err)
+	a.Equal("123", string(p))
+
+	_, err = r.Seek(8, io.SeekStart)
+	a.NoError(err)
+	rest, err := io.ReadAll(r)
+	a.NoError(err)
+	a.Equal("89", string(rest))
+}
+
+func Test_FtpsProtocol_explicitTLS(t *testing.T) {
+	a := require.New(t)
+	server := startFtpServer(t, "tester", "secret", true)
+
+	f := ufs.NewFileP(nil, server.Url("a.txt"), server.Credentials("secret"), 5*time.Second)
+	a.Equal("ftps", f.Protocol())
+
+	_, err := f.Upload(strings.NewReader("hello ftps"))
+	a.NoError(err)
+	a.Equal("hello ftps", ufs.ReadTextP(afero.NewOsFs(), server.Path("a.txt")))
+
+	a.Equal("hello ftps", ufs.DownloadTextP(nil, "", nil, f.Url(), f.Credentials(), 5*time.Second))
+	a.Equal(int64(len("hello ftps")), f.StatP().Size())
+
+	// the certificate is verified
+	_, err = ufs.NewFileP(nil, server.Url("a.txt"), &ufs.CredentialsT{User: "tester", Password: "secret", TLSConfig: &tls.Config{}}, 5*time.Second, ufs.WithRetry(ufs.NoRetry)).Stat()
+	a.Error(err)
+}
@@ -0,0 +1,182 @@
+package test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fclairamb/ftpserver/server"
+	"github.com/qiangyt/go-ufs"
+	"github.com/stretchr/testify/require"
+)
+
+type ftpServerT struct {
+	Addr string
+	// the local directory served as the ftp root
+	Dir  string
+	User string
+	// the client TLS config trusting the server certificate, nil if TLS is not enabled
+	TLSConfig *tls.Config
+}
+
+type ftpServer = *ftpServerT
+
+// Url returns the ftp:// url of the path under the root, or the ftps:// url if TLS is enabled
+func (me ftpServer) Url(p string) string {
+	if me.TLSConfig != nil {
+		return ufs.FTPS + me.Addr + "/" + p
+	}
+	return ufs.FTP + me.Addr + "/" + p
+}
+
+func (me ftpServer) Credentials(password string) ufs.Credentials {
+	return &ufs.CredentialsT{User: me.User, Password: password, TLSConfig: me.TLSConfig}
+}
+
+// Path returns the local path of the path under the root
+func (me ftpServer) Path(p string) string {
+	return filepath.Join(me.Dir, filepath.FromSlash(p))
+}
+
+// startFtpServer starts an in-process ftp server which serves a temporary directory. The server
+// accepts only the given user/password, and supports explicit TLS (AUTH TLS) if withTLS.
+func startFtpServer(t *testing.T, user string, password string, withTLS bool) ftpServer {
+	a := require.New(t)
+
+	driver := &ftpDriverT{
+		dir:      t.TempDir(),
+		user:     user,
+		password: password,
+		settings: &server.Settings{ListenAddr: "127.0.0.1:0"},
+	}
+	r := &ftpServerT{Dir: driver.dir, User: user}
+
+	if withTLS {
+		// borrows the self-signed certificate of httptest
+		https := httptest.NewTLSServer(nil)
+		https.Close()
+		driver.tlsConfig = &tls.Config{Certificates: https.TLS.Certificates}
+
+		pool := x509.NewCertPool()
+		pool.AddCert(https.Certificate())
+		r.TLSConfig = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+	}
+
+	s := server.NewFtpServer(driver)
+	a.NoError(s.Listen())
+	go s.Serve()
+	t.Cleanup(s.Stop)
+
+	r.Addr = s.Addr()
+	return r
+}
+
+// ftpDriverT serves the directory to the only user
+type ftpDriverT struct {
+	dir       string
+	user      string
+	password  string
+	settings  *server.Settings
+	tlsConfig *tls.Config
+}
+
+type ftpDriver = *ftpDriverT
+
+func (me ftpDriver) GetSettings() (*server.Settings, error) {
+	return me.settings, nil
+}
+
+func (me ftpDriver) WelcomeUser(cc server.ClientContext) (string, error) {
+	return "ufs test server", nil
+}
+
+func (me ftpDriver) UserLeft(cc server.ClientContext) {
+}
+
+func (me ftpDriver) AuthUser(cc server.ClientContext, user, pass string) (server.ClientHandlingDriver, error) {
+	if user != me.user || pass != me.password {
+		return nil, errors.New("bad username or password")
+	}
+	return me, nil
+}
+
+func (me ftpDriver) GetTLSConfig() (*tls.Config, error) {
+	if me.tlsConfig == nil {
+		return nil, errors.New("TLS is not enabled")
+	}
+	return me.tlsConfig, nil
+}
+
+func (me ftpDriver) path(p string) string {
+	return filepath.Join(me.dir, filepath.FromSlash(p))
+}
+
+func (me ftpDriver) ChangeDirectory(cc server.ClientContext, directory string) error {
+	fi, err := os.Stat(me.path(directory))
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errors.New("not a directory")
+	}
+	return nil
+}
+
+func (me ftpDriver) MakeDirectory(cc server.ClientContext, directory string) error {
+	return os.Mkdir(me.path(directory), 0o755)
+}
+
+func (me ftpDriver) ListFiles(cc server.ClientContext) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(me.path(cc.Path()))
+	if err != nil {
+		return nil, err
+	}
+	r := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, fi)
+	}
+	return r, nil
+}
+
+func (me ftpDriver) OpenFile(cc server.ClientContext, path string, flag int) (server.FileStream, error) {
+	if flag&os.O_WRONLY != 0 {
+		flag |= os.O_CREATE
+		if flag&os.O_APPEND == 0 {
+			flag |= os.O_TRUNC
+		}
+	}
+	return os.OpenFile(me.path(path), flag, 0o644)
+}
+
+func (me ftpDriver) DeleteFile(cc server.ClientContext, path string) error {
+	return os.Remove(me.path(path))
+}
+
+// GetFileInfo serves SIZE and MDTM, which apply to files only (RFC 3659)
+func (me ftpDriver) GetFileInfo(cc server.ClientContext, path string) (os.FileInfo, error) {
+	fi, err := os.Stat(me.path(path))
+	if err == nil && fi.IsDir() {
+		return nil, errors.New("not a plain file")
+	}
+	return fi, err
+}
+
+func (me ftpDriver) RenameFile(cc server.ClientContext, from, to string) error {
+	return os.Rename(me.path(from), me.path(to))
+}
+
+func (me ftpDriver) CanAllocate(cc server.ClientContext, size int) (bool, error) {
+	return true, nil
+}
+
+func (me ftpDriver) ChmodFile(cc server.ClientContext, path string, mode os.FileMode) error {
+	return os.Chmod(me.path(path), mode)
+}
@@ -0,0 +1,236 @@
+package test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/stretchr/testify/require"
+)
+
+func downloadString(a *require.Assertions, f ufs.File) string {
+	c, err := f.Download()
+	a.NoError(err)
+	defer c.Blob.Close()
+
+	b, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+	return string(b)
+}
+
+func Test_HttpAuth_headers(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	f := ufs.NewFileP(nil, server.URL+"/config.yaml", nil, 3*time.Second,
+		ufs.WithUserAgent("ufs-test/1.0"), ufs.WithHeader("X-Tenant", "a"), ufs.WithHeader("X-Tenant", "b"),
+		ufs.WithAuthenticator(ufs.BearerAuth("token")))
+
+	a.Equal("ok", downloadString(a, f))
+	a.Equal("ufs-test/1.0", header.Get("User-Agent"))
+	a.Equal([]string{"a", "b"}, header.Values("X-Tenant"))
+	a.Equal("Bearer token", header.Get("Authorization"))
+
+	f.StatP()
+	a.Equal("Bearer token", header.Get("Authorization"))
+
+	f.UploadP(strings.NewReader("x"))
+	a.Equal("ufs-test/1.0", header.Get("User-Agent"))
+	a.Equal("Bearer token", header.Get("Authorization"))
+}
+
+func Test_HttpAuth_ApiKeyAuth(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	f := ufs.NewFileP(nil, server.URL+"/x", nil, 3*time.Second, ufs.WithAuthenticator(ufs.ApiKeyAuth("X-API-Key", "secret")))
+	a.Equal("ok", downloadString(a, f))
+
+	f = ufs.NewFileP(nil, server.URL+"/x", nil, 3*time.Second, ufs.WithAuthenticator(ufs.ApiKeyAuth("X-API-Key", "wrong")))
+	_, err := f.Download()
+	a.ErrorContains(err, "401")
+}
+
+func Test_HttpAuth_redirect(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var header http.Header
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		io.WriteString(w, "other")
+	}))
+	defer other.Close()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/other":
+			http.Redirect(w, r, other.URL+"/x", http.StatusFound)
+		case "/same":
+			http.Redirect(w, r, server.URL+"/x", http.StatusFound)
+		default:
+			header = r.Header.Clone()
+			io.WriteString(w, "same")
+		}
+	}))
+	defer server.Close()
+
+	options := []ufs.Option{ufs.WithUserAgent("ufs-test/1.0"), ufs.WithHeader("X-Tenant", "a"), ufs.WithAuthenticator(ufs.ApiKeyAuth("X-API-Key", "secret"))}
+
+	// kept for the same host
+	a.Equal("same", downloadString(a, ufs.NewFileP(nil, server.URL+"/same", nil, 3*time.Second, options...)))
+	a.Equal("secret", header.Get("X-API-Key"))
+	a.Equal("a", header.Get("X-Tenant"))
+
+	// but not sent to another host
+	a.Equal("other", downloadString(a, ufs.NewFileP(nil, server.URL+"/other", nil, 3*time.Second, options...)))
+	a.Empty(header.Get("X-API-Key"))
+	a.Empty(header.Get("X-Tenant"))
+	a.Equal("ufs-test/1.0", header.Get("User-Agent"))
+}
+
+func Test_HttpAuth_BearerAuthFunc(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	n := 0
+	f := ufs.NewFileP(nil, server.URL+"/x", nil, 3*time.Second, ufs.WithAuthenticator(ufs.BearerAuthFunc(func() (string, error) {
+		n++
+		return fmt.Sprintf("token-%d", n), nil
+	})))
+
+	f.StatP()
+	a.Equal("Bearer token-1", authorization)
+	f.StatP()
+	a.Equal("Bearer token-2", authorization)
+}
+
+// digestServer verifies the digest access authentication, with qop "auth"
+type digestServer struct {
+	mutex     sync.Mutex
+	algorithm string
+	nonce     int
+	// the number of 401 responses
+	challenges int
+	body       string
+}
+
+func (me *digestServer) hash(s string) string {
+	var h hash.Hash = md5.New()
+	if strings.HasPrefix(me.algorithm, "SHA-256") {
+		h = sha256.New()
+	}
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (me *digestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	params := map[string]string{}
+	scheme, authorization, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	for _, param := range strings.Split(authorization, ", ") {
+		name, value, _ := strings.Cut(param, "=")
+		params[name] = strings.Trim(value, `"`)
+	}
+
+	nonce := fmt.Sprintf("nonce-%d", me.nonce)
+	realm := "test, realm"
+	ha1 := me.hash("u:" + realm + ":p")
+	if strings.HasSuffix(me.algorithm, "-sess") {
+		ha1 = me.hash(ha1 + ":" + nonce + ":" + params["cnonce"])
+	}
+	ha2 := me.hash(r.Method + ":" + r.URL.RequestURI())
+	expected := me.hash(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+
+	if scheme != "Digest" || params["nonce"] != nonce || params["uri"] != r.URL.RequestURI() ||
+		params["opaque"] != "opaque" || params["response"] != expected {
+		me.challenges++
+		w.Header().Add("WWW-Authenticate", `Basic realm="other"`)
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", qop="auth,auth-int", algorithm=%s, nonce="%s", opaque="opaque"`, realm, me.algorithm, nonce))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		b, _ := io.ReadAll(r.Body)
+		me.body = string(b)
+	}
+	io.WriteString(w, "digest ok")
+}
+
+func Test_HttpAuth_DigestAuth(t *testing.T) {
+	isolateCredentials(t)
+	for _, algorithm := range []string{"MD5", "SHA-256", "MD5-sess"} {
+		t.Run(algorithm, func(t *testing.T) {
+			a := require.New(t)
+
+			handler := &digestServer{algorithm: algorithm}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			f := ufs.NewFileP(nil, server.URL+"/x?y=1", nil, 3*time.Second, ufs.WithAuthenticator(ufs.DigestAuth("u", "p")))
+			a.Equal("digest ok", downloadString(a, f))
+			a.Equal(1, handler.challenges)
+
+			// answers the same challenge, with increasing nonce count
+			f.StatP()
+			a.Equal(1, handler.challenges)
+
+			// the upload content is not replayable, so the challenge is got in advance
+			handler.nonce++
+			f = ufs.NewFileP(nil, server.URL+"/x?y=1", nil, 3*time.Second, ufs.WithAuthenticator(ufs.DigestAuth("u", "p")))
+			f.UploadP(io.MultiReader(strings.NewReader("digest upload")))
+			a.Equal("digest upload", handler.body)
+			a.Equal(2, handler.challenges)
+		})
+	}
+}
+
+func Test_HttpAuth_DigestAuth_wrongPassword(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	handler := &digestServer{algorithm: "MD5"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	f := ufs.NewFileP(nil, server.URL+"/x", nil, 3*time.Second, ufs.WithAuthenticator(ufs.DigestAuth("u", "wrong")))
+	_, err := f.Download()
+	a.ErrorContains(err, "401")
+	a.Equal(2, handler.challenges)
+}
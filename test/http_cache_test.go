@@ -0,0 +1,209 @@
+package test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// cachedServer serves the content with the validators, and 304 on the matched conditional request
+type cachedServer struct {
+	mutex        sync.Mutex
+	content      string
+	etag         string
+	lastModified string
+	cacheControl string
+
+	// the requests received: "200" or "304"
+	responses []string
+	// the conditional headers of the latest request
+	ifNoneMatch     string
+	ifModifiedSince string
+}
+
+func (me *cachedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	me.ifNoneMatch = r.Header.Get("If-None-Match")
+	me.ifModifiedSince = r.Header.Get("If-Modified-Since")
+
+	if me.cacheControl != "" {
+		w.Header().Set("Cache-Control", me.cacheControl)
+	}
+	if me.etag != "" {
+		w.Header().Set("ETag", me.etag)
+	}
+	if me.lastModified != "" {
+		w.Header().Set("Last-Modified", me.lastModified)
+	}
+
+	if (me.etag != "" && me.ifNoneMatch == me.etag) || (me.etag == "" && me.lastModified != "" && me.ifModifiedSince == me.lastModified) {
+		me.responses = append(me.responses, "304")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	me.responses = append(me.responses, "200")
+	io.WriteString(w, me.content)
+}
+
+func (me *cachedServer) Responses() []string {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return append([]string{}, me.responses...)
+}
+
+func newCachedServer(t *testing.T, handler *cachedServer) (ufs.HttpCache, string, func() string) {
+	isolateCredentials(t)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cache := ufs.NewHttpCache(afero.NewMemMapFs(), "/cache")
+	url := server.URL + "/config.yaml"
+	download := func() string {
+		return string(ufs.DownloadBytesP(nil, "", nil, url, nil, 3*time.Second, ufs.WithHttpCache(cache)))
+	}
+	return cache, url, download
+}
+
+func Test_HttpCache_ETag(t *testing.T) {
+	a := require.New(t)
+	handler := &cachedServer{content: "v1", etag: `"1"`}
+	cache, url, download := newCachedServer(t, handler)
+
+	a.Equal("v1", download())
+	a.Equal("v1", download())
+	a.Equal(`"1"`, handler.ifNoneMatch)
+	a.Equal([]string{"200", "304"}, handler.Responses())
+
+	handler.content, handler.etag = "v2", `"2"`
+	a.Equal("v2", download())
+	a.Equal("v2", download())
+	a.Equal([]string{"200", "304", "200", "304"}, handler.Responses())
+
+	cache.RemoveP(url)
+	a.Equal("v2", download())
+	a.Equal("", handler.ifNoneMatch)
+}
+
+func Test_HttpCache_LastModified(t *testing.T) {
+	a := require.New(t)
+	lastModified := time.Date(2022, 10, 1, 8, 30, 0, 0, time.UTC).Format(http.TimeFormat)
+	handler := &cachedServer{content: "v1", lastModified: lastModified}
+	_, _, download := newCachedServer(t, handler)
+
+	a.Equal("v1", download())
+	a.Equal("v1", download())
+	a.Equal(lastModified, handler.ifModifiedSince)
+	a.Equal("", handler.ifNoneMatch)
+	a.Equal([]string{"200", "304"}, handler.Responses())
+}
+
+func Test_HttpCache_maxAge(t *testing.T) {
+	a := require.New(t)
+	handler := &cachedServer{content: "v1", etag: `"1"`, cacheControl: "public, max-age=60"}
+	_, _, download := newCachedServer(t, handler)
+
+	a.Equal("v1", download())
+	handler.content, handler.etag = "v2", `"2"`
+
+	// fresh, so served without request
+	a.Equal("v1", download())
+	a.Equal([]string{"200"}, handler.Responses())
+}
+
+func Test_HttpCache_maxAge_revalidated(t *testing.T) {
+	a := require.New(t)
+	handler := &cachedServer{content: "v1", etag: `"1"`, cacheControl: "max-age=0"}
+	_, _, download := newCachedServer(t, handler)
+
+	a.Equal("v1", download())
+	a.Equal("v1", download())
+	a.Equal([]string{"200", "304"}, handler.Responses())
+
+	// the 304 response makes it fresh again
+	handler.cacheControl = "max-age=60"
+	a.Equal("v1", download())
+	a.Equal("v1", download())
+	a.Equal([]string{"200", "304", "304"}, handler.Responses())
+}
+
+func Test_HttpCache_noStore(t *testing.T) {
+	a := require.New(t)
+	handler := &cachedServer{content: "v1", etag: `"1"`, cacheControl: "no-store"}
+	_, _, download := newCachedServer(t, handler)
+
+	a.Equal("v1", download())
+	a.Equal("v1", download())
+	a.Equal("", handler.ifNoneMatch)
+	a.Equal([]string{"200", "200"}, handler.Responses())
+}
+
+func Test_HttpCache_variant(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		body := "tenant=" + r.Header.Get("X-Tenant") + " user=" + user + " key=" + r.Header.Get("X-API-Key")
+		requests = append(requests, body)
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	cache := ufs.NewHttpCache(afero.NewMemMapFs(), "/cache")
+	url := server.URL + "/config.yaml"
+	download := func(credentials ufs.Credentials, options ...ufs.Option) string {
+		options = append(options, ufs.WithHttpCache(cache))
+		return string(ufs.DownloadBytesP(nil, "", nil, url, credentials, 3*time.Second, options...))
+	}
+
+	// by the request headers
+	a.Equal("tenant=a user= key=", download(nil, ufs.WithHeader("X-Tenant", "a")))
+	a.Equal("tenant=b user= key=", download(nil, ufs.WithHeader("X-Tenant", "b")))
+	a.Equal("tenant=a user= key=", download(nil, ufs.WithHeader("X-Tenant", "a")))
+
+	// by the identity
+	a.Equal("tenant= user=alice key=", download(&ufs.CredentialsT{User: "alice", Password: "1"}))
+	a.Equal("tenant= user=bob key=", download(&ufs.CredentialsT{User: "bob", Password: "2"}))
+	a.Equal("tenant= user= key=k1", download(nil, ufs.WithAuthenticator(ufs.ApiKeyAuth("X-API-Key", "k1"))))
+	a.Equal("tenant= user= key=k2", download(nil, ufs.WithAuthenticator(ufs.ApiKeyAuth("X-API-Key", "k2"))))
+	a.Equal("tenant= user=alice key=", download(&ufs.CredentialsT{User: "alice", Password: "1"}))
+
+	// the fresh ones are served without request
+	a.Len(requests, 6)
+
+	// all variants are removed
+	cache.RemoveP(url)
+	a.Equal("tenant=a user= key=", download(nil, ufs.WithHeader("X-Tenant", "a")))
+	a.Equal("tenant= user=alice key=", download(&ufs.CredentialsT{User: "alice", Password: "1"}))
+	a.Len(requests, 8)
+}
+
+func Test_HttpCache_formerLayout(t *testing.T) {
+	a := require.New(t)
+	handler := &cachedServer{content: "v1", etag: `"1"`}
+	_, url, _ := newCachedServer(t, handler)
+
+	// the entry keyed by url only
+	sum := sha256.Sum256([]byte(url))
+	fs := afero.NewMemMapFs()
+	cache := ufs.NewHttpCache(fs, "/cache")
+	ufs.WriteTextP(fs, "/cache/"+hex.EncodeToString(sum[:]), "stale")
+	ufs.WriteTextP(fs, "/cache/"+hex.EncodeToString(sum[:])+".yaml", "url: "+url+"\netag: '\"0\"'\n")
+
+	a.Equal("v1", string(ufs.DownloadBytesP(nil, "", nil, url, nil, 3*time.Second, ufs.WithHttpCache(cache))))
+	a.Equal("v1", string(ufs.DownloadBytesP(nil, "", nil, url, nil, 3*time.Second, ufs.WithHttpCache(cache))))
+	a.Equal([]string{"200", "304"}, handler.Responses())
+}
@@ -0,0 +1,135 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/stretchr/testify/require"
+)
+
+const nginxIndex = `<html>
+<head><title>Index of /pub/</title></head>
+<body>
+<h1>Index of /pub/</h1><hr><pre><a href="../">../</a>
+<a href="conf/">conf/</a>                                              01-Oct-2022 08:30                   -
+<a href="app%20v1.tar.gz">app v1.tar.gz</a>                                     02-Oct-2022 09:15             1048576
+<a href="readme.txt">readme.txt</a>                                        03-Oct-2022 10:00                  12
+</pre><hr></body>
+</html>
+`
+
+const apacheIndex = `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2 Final//EN">
+<html>
+ <head>
+  <title>Index of /pub</title>
+ </head>
+ <body>
+<h1>Index of /pub</h1>
+  <table>
+   <tr><th valign="top"><img src="/icons/blank.gif" alt="[ICO]"></th><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th><th><a href="?C=S;O=A">Size</a></th></tr>
+   <tr><th colspan="4"><hr></th></tr>
+<tr><td valign="top"><img src="/icons/back.gif" alt="[PARENTDIR]"></td><td><a href="/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td></tr>
+<tr><td valign="top"><a href="conf/"><img src="/icons/folder.gif" alt="[DIR]"></a></td><td><a href="conf/">conf/</a></td><td align="right">2022-10-01 08:30  </td><td align="right">  - </td></tr>
+<tr><td valign="top"><img src="/icons/compressed.gif" alt="[   ]"></td><td><a href="app.tar.gz">app.tar.gz</a></td><td align="right">2022-10-02 09:15  </td><td align="right">1.5M</td></tr>
+<tr><td valign="top"><img src="/icons/text.gif" alt="[TXT]"></td><td><a href="/pub/readme.txt">readme.txt</a></td><td align="right">2022-10-03 10:00  </td><td align="right"> 12 </td></tr>
+<tr><td valign="top"><img src="/icons/text.gif" alt="[TXT]"></td><td><a href="http://other.host/pub/x.txt">x.txt</a></td><td align="right">2022-10-03 10:00  </td><td align="right"> 12 </td></tr>
+   <tr><th colspan="4"><hr></th></tr>
+</table>
+</body></html>
+`
+
+const pythonIndex = `<!DOCTYPE HTML>
+<html lang="en">
+<head><title>Directory listing for /pub/</title></head>
+<body>
+<h1>Directory listing for /pub/</h1>
+<hr>
+<ul>
+<li><a href="conf/">conf/</a></li>
+<li><a href="readme.txt">readme.txt</a></li>
+</ul>
+<hr>
+</body>
+</html>
+`
+
+const nginxJsonIndex = `[
+{ "name":"conf", "type":"directory", "mtime":"Sat, 01 Oct 2022 08:30:00 GMT" },
+{ "name":"readme.txt", "type":"file", "mtime":"Mon, 03 Oct 2022 10:00:00 GMT", "size":12 }
+]`
+
+func listIndex(t *testing.T, contentType string, index string) map[string]os.FileInfo {
+	isolateCredentials(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pub" {
+			http.Redirect(w, r, "/pub/", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, index)
+	}))
+	t.Cleanup(server.Close)
+
+	entries := ufs.NewFileP(nil, server.URL+"/pub", nil, 3*time.Second).ListP()
+
+	r := map[string]os.FileInfo{}
+	for _, entry := range entries {
+		r[entry.Name()] = entry
+	}
+	require.Len(t, r, len(entries))
+	return r
+}
+
+func Test_HttpIndex_nginx(t *testing.T) {
+	a := require.New(t)
+	entries := listIndex(t, "text/html", nginxIndex)
+	a.Len(entries, 3)
+
+	a.True(entries["conf"].IsDir())
+	a.Equal(time.Date(2022, 10, 1, 8, 30, 0, 0, time.UTC), entries["conf"].ModTime())
+
+	a.False(entries["app v1.tar.gz"].IsDir())
+	a.Equal(int64(1048576), entries["app v1.tar.gz"].Size())
+	a.Equal(time.Date(2022, 10, 2, 9, 15, 0, 0, time.UTC), entries["app v1.tar.gz"].ModTime())
+
+	a.Equal(int64(12), entries["readme.txt"].Size())
+}
+
+func Test_HttpIndex_apache(t *testing.T) {
+	a := require.New(t)
+	entries := listIndex(t, "text/html;charset=UTF-8", apacheIndex)
+	a.Len(entries, 3)
+
+	a.True(entries["conf"].IsDir())
+	a.Equal(time.Date(2022, 10, 1, 8, 30, 0, 0, time.UTC), entries["conf"].ModTime())
+
+	a.Equal(int64(1.5*1024*1024), entries["app.tar.gz"].Size())
+	a.Equal(int64(12), entries["readme.txt"].Size())
+	a.Equal(time.Date(2022, 10, 3, 10, 0, 0, 0, time.UTC), entries["readme.txt"].ModTime())
+}
+
+func Test_HttpIndex_python(t *testing.T) {
+	a := require.New(t)
+	entries := listIndex(t, "text/html; charset=utf-8", pythonIndex)
+	a.Len(entries, 2)
+
+	a.True(entries["conf"].IsDir())
+	a.Equal(int64(-1), entries["readme.txt"].Size())
+	a.True(entries["readme.txt"].ModTime().IsZero())
+}
+
+func Test_HttpIndex_nginxJson(t *testing.T) {
+	a := require.New(t)
+	entries := listIndex(t, "application/json", nginxJsonIndex)
+	a.Len(entries, 2)
+
+	a.True(entries["conf"].IsDir())
+	a.Equal(time.Date(2022, 10, 1, 8, 30, 0, 0, time.UTC), entries["conf"].ModTime().UTC())
+	a.False(entries["readme.txt"].IsDir())
+	a.Equal(int64(12), entries["readme.txt"].Size())
+}
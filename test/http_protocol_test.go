@@ -0,0 +1,274 @@
+package test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HttpProtocol_Upload(t *testing.T) {
+	a := require.New(t)
+
+	var method, user, password, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		user, password, _ = r.BasicAuth()
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	cred := &ufs.CredentialsT{User: "u", Password: "p"}
+	f := ufs.NewFileP(nil, server.URL+"/upload/test.txt", cred, 3*time.Second)
+
+	n := f.UploadP(strings.NewReader("Test_HttpProtocol_Upload"))
+	a.Equal(int64(len("Test_HttpProtocol_Upload")), n)
+	a.Equal(http.MethodPut, method)
+	a.Equal("u", user)
+	a.Equal("p", password)
+	a.Equal("Test_HttpProtocol_Upload", body)
+}
+
+func Test_HttpProtocol_Create(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	w := ufs.NewFileP(nil, server.URL+"/test.txt", nil, 3*time.Second).CreateP()
+	_, err := io.WriteString(w, "Test_HttpProtocol_Create")
+	a.NoError(err)
+	a.NoError(w.Close())
+
+	a.Equal("Test_HttpProtocol_Create", body)
+}
+
+func Test_HttpProtocol_Upload_failed(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	f := ufs.NewFileP(nil, server.URL+"/test.txt", nil, 3*time.Second)
+	_, err := f.Upload(strings.NewReader("x"))
+	a.ErrorContains(err, "403")
+}
+
+func Test_HttpProtocol_Stat(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	lastModified := time.Date(2022, 10, 1, 8, 30, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/config.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		a.Equal(http.MethodHead, r.Method)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", "123")
+	}))
+	defer server.Close()
+
+	f := ufs.NewFileP(nil, server.URL+"/config.yaml", nil, 3*time.Second)
+	fi := f.StatP()
+	a.Equal("config.yaml", fi.Name())
+	a.Equal(int64(123), fi.Size())
+	a.True(lastModified.Equal(fi.ModTime()))
+	a.False(fi.IsDir())
+	a.Equal(`"v1"`, fi.(ufs.RemoteFileInfo).ETag)
+	a.True(f.ExistsP())
+
+	missing := ufs.NewFileP(nil, server.URL+"/missing.yaml", nil, 3*time.Second)
+	_, err := missing.Stat()
+	a.True(os.IsNotExist(err))
+	a.False(missing.ExistsP())
+}
+
+func Test_HttpProtocol_List_notSupported(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "not an index")
+	}))
+	defer server.Close()
+
+	_, err := ufs.NewFileP(nil, server.URL+"/dir/", nil, 3*time.Second).List()
+	a.ErrorIs(err, ufs.ErrNotSupported)
+}
+
+func Test_HttpProtocol_Remove(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	ufs.NewFileP(nil, server.URL+"/processed/a.txt", nil, 3*time.Second).RemoveP()
+	a.Equal(http.MethodDelete, method)
+	a.Equal("/processed/a.txt", path)
+}
+
+func Test_HttpProtocol_Download(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "Test_HttpProtocol_Download")
+	}))
+	defer server.Close()
+
+	c := ufs.NewFileP(nil, server.URL+"/dir/test.txt", nil, 3*time.Second).DownloadP()
+	defer c.Blob.Close()
+	a.Equal("test.txt", c.Name)
+
+	txt, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+	a.Equal("Test_HttpProtocol_Download", string(txt))
+}
+
+func Test_HttpProtocol_DownloadContext_cancel(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-unblock
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	begin := time.Now()
+	_, err := ufs.DownloadBytesContext(ctx, nil, "", nil, server.URL+"/slow.txt", nil, 10*time.Second)
+	a.ErrorIs(err, context.Canceled)
+	a.Less(time.Since(begin), 5*time.Second)
+}
+
+func Test_HttpProtocol_Download_streaming(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	secondHalf := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first ")
+		w.(http.Flusher).Flush()
+		<-secondHalf
+		io.WriteString(w, "second")
+	}))
+	defer server.Close()
+
+	// the content is available before the server sends the whole body
+	c := ufs.NewFileP(nil, server.URL+"/dir/test.txt", nil, 3*time.Second).DownloadP()
+	defer c.Blob.Close()
+	a.Equal("/dir/test.txt", c.Path)
+
+	close(secondHalf)
+	txt, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+	a.Equal("first second", string(txt))
+}
+
+func Test_HttpProtocol_Download_tempFile(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "Test_HttpProtocol_Download_tempFile")
+	}))
+	defer server.Close()
+
+	c := ufs.NewFileP(nil, server.URL+"/test.txt", nil, 3*time.Second, ufs.WithTempFile()).DownloadP()
+	osFs := afero.NewOsFs()
+	a.True(ufs.FileExistsP(osFs, c.Path))
+
+	txt, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+	a.Equal("Test_HttpProtocol_Download_tempFile", string(txt))
+
+	a.NoError(c.Blob.Close())
+	a.False(ufs.FileExistsP(osFs, c.Path))
+}
+
+func Test_HttpProtocol_OpenRandom(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "archive.zip", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer server.Close()
+
+	r := ufs.NewFileP(nil, server.URL+"/archive.zip", nil, 3*time.Second).OpenRandomP()
+	defer r.Close()
+	a.Equal(int64(10), r.Size())
+
+	trailer := make([]byte, 3)
+	n, err := r.ReadAt(trailer, 7)
+	a.NoError(err)
+	a.Equal(3, n)
+	a.Equal("789", string(trailer))
+	a.Equal("bytes=7-9", ranges[len(ranges)-1])
+
+	pos, err := r.Seek(-4, io.SeekEnd)
+	a.NoError(err)
+	a.Equal(int64(6), pos)
+	rest, err := io.ReadAll(r)
+	a.NoError(err)
+	a.Equal("6789", string(rest))
+	a.Equal("bytes=6-", ranges[len(ranges)-1])
+
+	n, err = r.ReadAt(make([]byte, 5), 8)
+	a.ErrorIs(err, io.EOF)
+	a.Equal(2, n)
+}
+
+func Test_HttpProtocol_OpenRandom_rangeNotSupported(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		io.WriteString(w, "0123456789")
+	}))
+	defer server.Close()
+
+	r := ufs.NewFileP(nil, server.URL+"/archive.zip", nil, 3*time.Second).OpenRandomP()
+	defer r.Close()
+
+	p := make([]byte, 4)
+	_, err := r.ReadAt(p, 3)
+	a.NoError(err)
+	a.Equal("3456", string(p))
+}
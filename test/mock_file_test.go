@@ -1,16 +1,20 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/fastgh/go-ufs (interfaces: File)
+// Source: github.com/qiangyt/go-ufs (interfaces: File)
 
 // Package test is a generated GoMock package.
 package test
 
 import (
+	context "context"
+	io "io"
+	fs "io/fs"
 	url "net/url"
 	reflect "reflect"
 	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/goodsru/go-universal-network-adapter/models"
+	ufs "github.com/qiangyt/go-ufs"
 )
 
 // MockFile is a mock of File interface.
@@ -36,11 +40,40 @@ func (m *MockFile) EXPECT() *MockFileMockRecorder {
 	return m.recorder
 }
 
+// Create mocks base method.
+func (m *MockFile) Create() (io.WriteCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create")
+	ret0, _ := ret[0].(io.WriteCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockFileMockRecorder) Create() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockFile)(nil).Create))
+}
+
+// CreateP mocks base method.
+func (m *MockFile) CreateP() io.WriteCloser {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateP")
+	ret0, _ := ret[0].(io.WriteCloser)
+	return ret0
+}
+
+// CreateP indicates an expected call of CreateP.
+func (mr *MockFileMockRecorder) CreateP() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateP", reflect.TypeOf((*MockFile)(nil).CreateP))
+}
+
 // Credentials mocks base method.
-func (m *MockFile) Credentials() *models.Credentials {
+func (m *MockFile) Credentials() *ufs.CredentialsT {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Credentials")
-	ret0, _ := ret[0].(*models.Credentials)
+	ret0, _ := ret[0].(*ufs.CredentialsT)
 	return ret0
 }
 
@@ -79,6 +112,35 @@ func (mr *MockFileMockRecorder) Download() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockFile)(nil).Download))
 }
 
+// DownloadContext mocks base method.
+func (m *MockFile) DownloadContext(arg0 context.Context) (*models.RemoteFileContent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DownloadContext", arg0)
+	ret0, _ := ret[0].(*models.RemoteFileContent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DownloadContext indicates an expected call of DownloadContext.
+func (mr *MockFileMockRecorder) DownloadContext(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadContext", reflect.TypeOf((*MockFile)(nil).DownloadContext), arg0)
+}
+
+// DownloadContextP mocks base method.
+func (m *MockFile) DownloadContextP(arg0 context.Context) *models.RemoteFileContent {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DownloadContextP", arg0)
+	ret0, _ := ret[0].(*models.RemoteFileContent)
+	return ret0
+}
+
+// DownloadContextP indicates an expected call of DownloadContextP.
+func (mr *MockFileMockRecorder) DownloadContextP(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadContextP", reflect.TypeOf((*MockFile)(nil).DownloadContextP), arg0)
+}
+
 // DownloadP mocks base method.
 func (m *MockFile) DownloadP() *models.RemoteFileContent {
 	m.ctrl.T.Helper()
@@ -93,6 +155,90 @@ func (mr *MockFileMockRecorder) DownloadP() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadP", reflect.TypeOf((*MockFile)(nil).DownloadP))
 }
 
+// Exists mocks base method.
+func (m *MockFile) Exists() (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exists")
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exists indicates an expected call of Exists.
+func (mr *MockFileMockRecorder) Exists() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockFile)(nil).Exists))
+}
+
+// ExistsP mocks base method.
+func (m *MockFile) ExistsP() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExistsP")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// ExistsP indicates an expected call of ExistsP.
+func (mr *MockFileMockRecorder) ExistsP() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExistsP", reflect.TypeOf((*MockFile)(nil).ExistsP))
+}
+
+// List mocks base method.
+func (m *MockFile) List() ([]fs.FileInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List")
+	ret0, _ := ret[0].([]fs.FileInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockFileMockRecorder) List() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockFile)(nil).List))
+}
+
+// ListP mocks base method.
+func (m *MockFile) ListP() []fs.FileInfo {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListP")
+	ret0, _ := ret[0].([]fs.FileInfo)
+	return ret0
+}
+
+// ListP indicates an expected call of ListP.
+func (mr *MockFileMockRecorder) ListP() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListP", reflect.TypeOf((*MockFile)(nil).ListP))
+}
+
+// Mkdir mocks base method.
+func (m *MockFile) Mkdir() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Mkdir")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Mkdir indicates an expected call of Mkdir.
+func (mr *MockFileMockRecorder) Mkdir() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Mkdir", reflect.TypeOf((*MockFile)(nil).Mkdir))
+}
+
+// MkdirP mocks base method.
+func (m *MockFile) MkdirP() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "MkdirP")
+}
+
+// MkdirP indicates an expected call of MkdirP.
+func (mr *MockFileMockRecorder) MkdirP() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MkdirP", reflect.TypeOf((*MockFile)(nil).MkdirP))
+}
+
 // Name mocks base method.
 func (m *MockFile) Name() string {
 	m.ctrl.T.Helper()
@@ -107,6 +253,49 @@ func (mr *MockFileMockRecorder) Name() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockFile)(nil).Name))
 }
 
+// OpenRandom mocks base method.
+func (m *MockFile) OpenRandom() (ufs.RandomReader, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenRandom")
+	ret0, _ := ret[0].(ufs.RandomReader)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OpenRandom indicates an expected call of OpenRandom.
+func (mr *MockFileMockRecorder) OpenRandom() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenRandom", reflect.TypeOf((*MockFile)(nil).OpenRandom))
+}
+
+// OpenRandomP mocks base method.
+func (m *MockFile) OpenRandomP() ufs.RandomReader {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenRandomP")
+	ret0, _ := ret[0].(ufs.RandomReader)
+	return ret0
+}
+
+// OpenRandomP indicates an expected call of OpenRandomP.
+func (mr *MockFileMockRecorder) OpenRandomP() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenRandomP", reflect.TypeOf((*MockFile)(nil).OpenRandomP))
+}
+
+// Options mocks base method.
+func (m *MockFile) Options() *ufs.OptionsT {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Options")
+	ret0, _ := ret[0].(*ufs.OptionsT)
+	return ret0
+}
+
+// Options indicates an expected call of Options.
+func (mr *MockFileMockRecorder) Options() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Options", reflect.TypeOf((*MockFile)(nil).Options))
+}
+
 // Protocol mocks base method.
 func (m *MockFile) Protocol() string {
 	m.ctrl.T.Helper()
@@ -121,6 +310,61 @@ func (mr *MockFileMockRecorder) Protocol() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Protocol", reflect.TypeOf((*MockFile)(nil).Protocol))
 }
 
+// Remove mocks base method.
+func (m *MockFile) Remove() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remove")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockFileMockRecorder) Remove() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockFile)(nil).Remove))
+}
+
+// RemoveP mocks base method.
+func (m *MockFile) RemoveP() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RemoveP")
+}
+
+// RemoveP indicates an expected call of RemoveP.
+func (mr *MockFileMockRecorder) RemoveP() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveP", reflect.TypeOf((*MockFile)(nil).RemoveP))
+}
+
+// Stat mocks base method.
+func (m *MockFile) Stat() (fs.FileInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stat")
+	ret0, _ := ret[0].(fs.FileInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stat indicates an expected call of Stat.
+func (mr *MockFileMockRecorder) Stat() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stat", reflect.TypeOf((*MockFile)(nil).Stat))
+}
+
+// StatP mocks base method.
+func (m *MockFile) StatP() fs.FileInfo {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatP")
+	ret0, _ := ret[0].(fs.FileInfo)
+	return ret0
+}
+
+// StatP indicates an expected call of StatP.
+func (mr *MockFileMockRecorder) StatP() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatP", reflect.TypeOf((*MockFile)(nil).StatP))
+}
+
 // Timeout mocks base method.
 func (m *MockFile) Timeout() time.Duration {
 	m.ctrl.T.Helper()
@@ -149,6 +393,35 @@ func (mr *MockFileMockRecorder) URL() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "URL", reflect.TypeOf((*MockFile)(nil).URL))
 }
 
+// Upload mocks base method.
+func (m *MockFile) Upload(arg0 io.Reader) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upload", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Upload indicates an expected call of Upload.
+func (mr *MockFileMockRecorder) Upload(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upload", reflect.TypeOf((*MockFile)(nil).Upload), arg0)
+}
+
+// UploadP mocks base method.
+func (m *MockFile) UploadP(arg0 io.Reader) int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadP", arg0)
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// UploadP indicates an expected call of UploadP.
+func (mr *MockFileMockRecorder) UploadP(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadP", reflect.TypeOf((*MockFile)(nil).UploadP), arg0)
+}
+
 // Url mocks base method.
 func (m *MockFile) Url() string {
 	m.ctrl.T.Helper()
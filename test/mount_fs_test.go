@@ -0,0 +1,155 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"testing"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func newMountFs(t *testing.T) (ufs.MountFs, afero.Fs, afero.Fs) {
+	etc := afero.NewMemMapFs()
+	ufs.WriteTextP(etc, "/hosts", "127.0.0.1 localhost")
+
+	tmp := afero.NewMemMapFs()
+
+	fs := ufs.NewMountFs(nil)
+	fs.MountP("/etc", etc, true)
+	fs.MountP("/tmp", tmp, false)
+	return fs, etc, tmp
+}
+
+func Test_MountFs_routing(t *testing.T) {
+	a := require.New(t)
+	fs, _, tmp := newMountFs(t)
+
+	a.Equal("127.0.0.1 localhost", ufs.ReadTextP(fs, "/etc/hosts"))
+
+	ufs.WriteTextP(fs, "/tmp/x.txt", "x")
+	a.Equal("x", ufs.ReadTextP(tmp, "/x.txt"))
+
+	ufs.WriteTextP(fs, "/home/y.txt", "y")
+	a.False(ufs.FileExistsP(tmp, "/home/y.txt"))
+	a.Equal("y", ufs.ReadTextP(fs, "/home/y.txt"))
+
+	mp, rel := fs.Resolve("/tmp/a/b.txt")
+	a.Equal("/tmp", mp.Path)
+	a.Equal("/a/b.txt", rel)
+	a.Same(tmp, mp.Fs)
+
+	mp, rel = fs.Resolve("/tmpfile")
+	a.Equal("/", mp.Path)
+	a.Equal("/tmpfile", rel)
+}
+
+func Test_MountFs_readOnly(t *testing.T) {
+	a := require.New(t)
+	fs, _, _ := newMountFs(t)
+
+	err := ufs.WriteText(fs, "/etc/passwd", "root")
+	a.ErrorIs(err, syscall.EPERM)
+	a.ErrorIs(fs.Remove("/etc/hosts"), syscall.EPERM)
+	a.ErrorIs(fs.Rename("/etc/hosts", "/tmp/hosts"), syscall.EPERM)
+
+	var pathErr *os.PathError
+	a.ErrorAs(fs.Mkdir("/etc/x", 0o755), &pathErr)
+	a.Equal("/etc/x", pathErr.Path)
+}
+
+func Test_MountFs_mounts(t *testing.T) {
+	a := require.New(t)
+	fs, _, _ := newMountFs(t)
+
+	var points []string
+	for _, mp := range fs.Mounts() {
+		points = append(points, mp.Path)
+	}
+	a.Equal([]string{"/", "/etc", "/tmp"}, points)
+	a.True(fs.Mounts()[1].ReadOnly)
+
+	a.ErrorContains(fs.Mount("/tmp/", afero.NewMemMapFs(), false), "already mounted")
+	a.ErrorContains(fs.Unmount("/"), "root")
+	a.ErrorContains(fs.Unmount("/var"), "not mounted")
+
+	a.ErrorIs(fs.RemoveAll("/tmp"), syscall.EBUSY)
+	a.ErrorIs(fs.Rename("/tmp", "/tmp2"), syscall.EBUSY)
+
+	fs.UnmountP("/tmp")
+	a.Len(fs.Mounts(), 2)
+	a.False(ufs.DirExistsP(fs, "/tmp"))
+}
+
+func Test_MountFs_readdir(t *testing.T) {
+	a := require.New(t)
+	fs, _, _ := newMountFs(t)
+	fs.MountP("/mnt/data/disk", afero.NewMemMapFs(), false)
+
+	ufs.WriteTextP(fs, "/readme.txt", "r")
+	ufs.WriteTextP(fs, "/tmp/a/b.txt", "b")
+
+	// the virtual parent directories of mount point
+	fi, err := fs.Stat("/mnt/data")
+	a.NoError(err)
+	a.True(fi.IsDir())
+	_, err = fs.Stat("/mnt/missing")
+	a.True(os.IsNotExist(err))
+
+	names, err := readDirNames(fs, "/")
+	a.NoError(err)
+	a.Equal([]string{"etc", "mnt", "readme.txt", "tmp"}, names)
+
+	names, err = readDirNames(fs, "/mnt")
+	a.NoError(err)
+	a.Equal([]string{"data"}, names)
+
+	var walked []string
+	a.NoError(afero.Walk(fs, "/", func(path string, info os.FileInfo, err error) error {
+		walked = append(walked, filepath.ToSlash(path))
+		return err
+	}))
+	a.Equal([]string{"/", "/etc", "/etc/hosts", "/mnt", "/mnt/data", "/mnt/data/disk", "/readme.txt", "/tmp", "/tmp/a", "/tmp/a/b.txt"}, walked)
+}
+
+func readDirNames(fs afero.Fs, dir string) ([]string, error) {
+	f, err := fs.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	sort.Strings(names)
+	return names, err
+}
+
+func Test_MountFs_Rename(t *testing.T) {
+	a := require.New(t)
+	fs, _, tmp := newMountFs(t)
+
+	sftpFs, root := newSftpFs(t)
+	fs.MountP("/remote", sftpFs, false)
+
+	// within the mount point
+	ufs.WriteTextP(fs, "/tmp/a.txt", "a")
+	a.NoError(fs.Rename("/tmp/a.txt", "/tmp/b.txt"))
+	a.Equal("a", ufs.ReadTextP(tmp, "/b.txt"))
+
+	// across the mount points
+	a.NoError(fs.Rename("/tmp/b.txt", "/remote/b.txt"))
+	a.False(ufs.FileExistsP(tmp, "/b.txt"))
+	a.Equal("a", ufs.ReadTextP(afero.NewOsFs(), filepath.Join(root, "b.txt")))
+
+	ufs.WriteTextP(fs, "/tmp/dir/1.txt", "1")
+	ufs.WriteTextP(fs, "/tmp/dir/sub/2.txt", "2")
+	a.NoError(fs.Rename("/tmp/dir", "/remote/moved"))
+	a.False(ufs.DirExistsP(tmp, "/dir"))
+	a.Equal("1", ufs.ReadTextP(fs, "/remote/moved/1.txt"))
+	a.Equal("2", ufs.ReadTextP(fs, "/remote/moved/sub/2.txt"))
+
+	a.True(os.IsNotExist(fs.Rename("/tmp/missing", "/remote/x")))
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func collectProgress(progresses *[]ufs.ProgressT) ufs.ProgressFunc {
+	return func(progress ufs.Progress) {
+		*progresses = append(*progresses, *progress)
+	}
+}
+
+func Test_Progress_CopyFile(t *testing.T) {
+	a := require.New(t)
+
+	fs := afero.NewMemMapFs()
+	ufs.WriteTextP(fs, "/src.txt", "Test_Progress_CopyFile")
+
+	var progresses []ufs.ProgressT
+	n := ufs.CopyFileP(fs, "/src.txt", "/dst.txt", ufs.WithProgress(collectProgress(&progresses)))
+	a.Equal(int64(22), n)
+	a.Equal("Test_Progress_CopyFile", ufs.ReadTextP(fs, "/dst.txt"))
+
+	a.NotEmpty(progresses)
+	last := progresses[len(progresses)-1]
+	a.True(last.Finished)
+	a.Equal(int64(22), last.Done)
+	a.Equal(int64(22), last.Total)
+	a.Equal(time.Duration(0), last.ETA)
+}
+
+func Test_Progress_AferoFile_Download(t *testing.T) {
+	a := require.New(t)
+
+	fs := afero.NewMemMapFs()
+	ufs.WriteTextP(fs, "/test.txt", "Test_Progress_AferoFile_Download")
+
+	var progresses []ufs.ProgressT
+	f := ufs.NewFileP(fs, "/test.txt", nil, 0, ufs.WithProgress(collectProgress(&progresses)))
+
+	c := f.DownloadP()
+	_, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+
+	last := progresses[len(progresses)-1]
+	a.True(last.Finished)
+	a.Equal(int64(32), last.Done)
+	a.Equal(int64(32), last.Total)
+}
+
+func Test_Progress_Http_Download(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/SHA256SUMS" {
+			io.WriteString(w, sha256Hex("Test_Progress_Http_Download")+"  test.txt\n")
+			return
+		}
+		http.ServeContent(w, r, "test.txt", time.Time{}, strings.NewReader("Test_Progress_Http_Download"))
+	}))
+	defer server.Close()
+
+	var progresses []ufs.ProgressT
+	f := ufs.NewFileP(nil, server.URL+"/test.txt", nil, 3*time.Second, ufs.WithProgress(collectProgress(&progresses)))
+
+	c := f.DownloadP()
+	defer c.Blob.Close()
+	_, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+
+	last := progresses[len(progresses)-1]
+	a.True(last.Finished)
+	a.Equal(int64(27), last.Done)
+	a.Equal(int64(27), last.Total)
+
+	// the total is the Content-Length, without another request
+	a.Equal([]string{"GET /test.txt"}, requests)
+
+	// the checksums file is not reported
+	requests, progresses = nil, nil
+	f = ufs.NewFileP(nil, server.URL+"/test.txt", nil, 3*time.Second, ufs.WithChecksumsFile("SHA256SUMS"), ufs.WithProgress(collectProgress(&progresses)))
+	c = f.DownloadP()
+	defer c.Blob.Close()
+	_, err = io.ReadAll(c.Blob)
+	a.NoError(err)
+	a.Equal([]string{"GET /SHA256SUMS", "GET /test.txt"}, requests)
+	for _, progress := range progresses {
+		a.Equal(int64(27), progress.Total)
+	}
+}
+
+func Test_Progress_Http_Upload(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var contentLength int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	var progresses []ufs.ProgressT
+	f := ufs.NewFileP(nil, server.URL+"/test.txt", nil, 3*time.Second, ufs.WithProgress(collectProgress(&progresses)))
+
+	f.UploadP(strings.NewReader("Test_Progress_Http_Upload"))
+	a.Equal(int64(25), contentLength)
+
+	last := progresses[len(progresses)-1]
+	a.True(last.Finished)
+	a.Equal(int64(25), last.Done)
+	a.Equal(int64(25), last.Total)
+}
@@ -0,0 +1,124 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/stretchr/testify/require"
+)
+
+// memProtocol is an in-house protocol keeping the files in memory
+type memProtocol struct {
+	files map[string]string
+}
+
+func (me *memProtocol) Download(ctx context.Context, f ufs.RemoteFile) (ufs.Content, error) {
+	text, found := me.files[f.URL().Path]
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	return ufs.StreamContent(f, io.NopCloser(bytes.NewReader([]byte(text)))), nil
+}
+
+func (me *memProtocol) Upload(ctx context.Context, f ufs.RemoteFile, reader io.Reader) (int64, error) {
+	b, err := io.ReadAll(reader)
+	me.files[f.URL().Path] = string(b)
+	return int64(len(b)), err
+}
+
+func (me *memProtocol) Stat(ctx context.Context, f ufs.RemoteFile) (os.FileInfo, error) {
+	text, found := me.files[f.URL().Path]
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	return &ufs.RemoteFileInfoT{Filename: f.Name(), Length: int64(len(text))}, nil
+}
+
+func (me *memProtocol) List(ctx context.Context, f ufs.RemoteFile) ([]os.FileInfo, error) {
+	return nil, ufs.ErrNotSupported
+}
+
+func (me *memProtocol) Remove(ctx context.Context, f ufs.RemoteFile) error {
+	delete(me.files, f.URL().Path)
+	return nil
+}
+
+func (me *memProtocol) OpenRandom(ctx context.Context, f ufs.RemoteFile) (ufs.RandomReader, error) {
+	return nil, ufs.ErrNotSupported
+}
+
+func (me *memProtocol) Retryable(err error) bool {
+	return false
+}
+
+func Test_RegisterProtocol(t *testing.T) {
+	a := require.New(t)
+
+	a.False(ufs.IsRemote("mem://host/hello.txt"))
+
+	mem := &memProtocol{files: map[string]string{"/hello.txt": "Test_RegisterProtocol"}}
+	ufs.RegisterProtocol("MEM", mem)
+	defer ufs.RegisterProtocol("mem", nil)
+
+	a.Same(mem, ufs.LookupProtocol("mem"))
+	a.True(ufs.IsRemote("mem://host/hello.txt"))
+	a.Equal("defaultDir", ufs.WorkDir("mem://host/hello.txt", "defaultDir"))
+
+	text := ufs.DownloadTextP(nil, "", nil, "mem://host/hello.txt", nil, time.Second)
+	a.Equal("Test_RegisterProtocol", text)
+
+	f := ufs.NewFileP(nil, "mem://host/new.txt", nil, time.Second)
+	a.IsType(&ufs.RemoteFileT{}, f)
+	f.UploadP(bytes.NewReader([]byte("new")))
+	a.Equal("new", mem.files["/new.txt"])
+	a.Equal(int64(3), f.StatP().Size())
+}
+
+func Test_RegisterProtocol_unregister(t *testing.T) {
+	a := require.New(t)
+
+	ufs.RegisterProtocol("mem", &memProtocol{})
+	ufs.RegisterProtocol("mem", nil)
+
+	a.Nil(ufs.LookupProtocol("mem"))
+	a.False(ufs.IsRemote("mem://host/hello.txt"))
+}
+
+func Test_RegisterProtocol_override(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "from server")
+	}))
+	defer server.Close()
+
+	builtin := ufs.LookupProtocol("http")
+	a.NotNil(builtin)
+
+	downloaded := false
+	ufs.RegisterProtocol("http", &overrideProtocol{Protocol: builtin, downloaded: &downloaded})
+	defer ufs.RegisterProtocol("http", builtin)
+
+	text := ufs.DownloadTextP(nil, "", nil, server.URL+"/test.txt", nil, time.Second)
+	a.Equal("from server", text)
+	a.True(downloaded)
+}
+
+// overrideProtocol decorates the built-in http handler
+type overrideProtocol struct {
+	ufs.Protocol
+	downloaded *bool
+}
+
+func (me *overrideProtocol) Download(ctx context.Context, f ufs.RemoteFile) (ufs.Content, error) {
+	*me.downloaded = true
+	return me.Protocol.Download(ctx, f)
+}
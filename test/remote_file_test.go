@@ -11,6 +11,7 @@ import (
 
 func Test_RemoteFile_happy(t *testing.T) {
 	a := require.New(t)
+	isolateCredentials(t)
 
 	// cred := &CredentialsT{}
 	actual := ufs.NewRemoteFileP("https://mirror.sjtu.edu.cn/debian/README.mirrors.txt", nil, 10*time.Second)
@@ -0,0 +1,210 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadRemotesFile(t *testing.T) {
+	a := require.New(t)
+	t.Setenv("BACKUP_SECRET_KEY", "s3cret")
+
+	fs := afero.NewMemMapFs()
+	ufs.WriteTextP(fs, "/remotes.yaml", `
+prod:
+  url: sftp://example.com:2222/srv/data
+  user: alice
+  privateKeyFile: ~/.ssh/id_ed25519
+  hostKeyPolicy: tofu
+  sshAgent: true
+  timeout: 30s
+backup:
+  url: s3://minio:9000/backup
+  user: key
+  password: ${BACKUP_SECRET_KEY}
+  timeout: 45
+  s3:
+    region: eu-west-1
+    addressingStyle: path
+    anonymous: false
+public:
+  url: https://example.com/pub/
+`)
+	remotes := ufs.LoadRemotesFileP(fs, "/remotes.yaml")
+	a.Len(remotes, 3)
+
+	prod := remotes["prod"]
+	a.Equal("prod", prod.Name)
+	a.Equal("sftp://example.com:2222/srv/data", prod.Url)
+	a.Equal("alice", prod.Credentials.User)
+	a.Equal("~/.ssh/id_ed25519", prod.Credentials.PrivateKeyFile)
+	a.Equal(ufs.HostKeyTrustOnFirstUse, prod.Credentials.HostKeyPolicy)
+	a.True(prod.Credentials.SshAgent)
+	a.Equal(30*time.Second, prod.Timeout)
+	a.Nil(prod.S3)
+
+	backup := remotes["backup"]
+	a.Equal("s3cret", backup.Credentials.Password)
+	a.Equal(45*time.Second, backup.Timeout)
+	a.Equal("eu-west-1", backup.S3.Region)
+	a.Equal(ufs.S3PathStyle, backup.S3.AddressingStyle)
+
+	public := remotes["public"]
+	a.Nil(public.Credentials)
+	a.Zero(public.Timeout)
+}
+
+func Test_LoadRemotesFile_invalid(t *testing.T) {
+	a := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	ufs.WriteTextP(fs, "/remotes.yaml", "prod:\n  user: alice\n")
+	_, err := ufs.LoadRemotesFile(fs, "/remotes.yaml")
+	a.ErrorContains(err, "prod.url is required")
+
+	ufs.WriteTextP(fs, "/remotes.yaml", "prod:\n  url: https://example.com\n  timeout: soon\n")
+	_, err = ufs.LoadRemotesFile(fs, "/remotes.yaml")
+	a.ErrorContains(err, "prod.timeout must be a duration")
+
+	ufs.WriteTextP(fs, "/remotes.yaml", "prod: https://example.com\n")
+	_, err = ufs.LoadRemotesFile(fs, "/remotes.yaml")
+	a.ErrorContains(err, "prod must be a map")
+}
+
+func Test_Remote_Expand(t *testing.T) {
+	a := require.New(t)
+
+	remote := &ufs.RemoteT{Url: "sftp://example.com:2222/srv/data"}
+	a.Equal("sftp://example.com:2222/srv/data", remote.Expand(""))
+	a.Equal("sftp://example.com:2222/srv/data/a/b.yaml", remote.Expand("a/b.yaml"))
+	a.Equal("sftp://example.com:2222/srv/data/a/b.yaml", remote.Expand("/a/b.yaml"))
+	a.Equal("sftp://example.com:2222/srv/data/a/", remote.Expand("a/"))
+
+	remote = &ufs.RemoteT{Url: "https://example.com"}
+	a.Equal("https://example.com/a.txt", remote.Expand("a.txt"))
+}
+
+func Test_ResolveRemote(t *testing.T) {
+	a := require.New(t)
+	t.Setenv(ufs.RemotesFileEnv, filepath.Join(t.TempDir(), "missing.yaml"))
+
+	ufs.RegisterRemote("Test_ResolveRemote", &ufs.RemoteT{Url: "https://example.com/pub"})
+	t.Cleanup(func() { ufs.RegisterRemote("Test_ResolveRemote", nil) })
+
+	remote, url, err := ufs.ResolveRemote("Test_ResolveRemote:a/b.txt")
+	a.NoError(err)
+	a.Equal("Test_ResolveRemote", remote.Name)
+	a.Equal("https://example.com/pub/a/b.txt", url)
+	a.True(ufs.IsRemote("Test_ResolveRemote:a/b.txt"))
+
+	for _, unchanged := range []string{"unknown:a/b.txt", `C:\dir\a.txt`, "C:/dir/a.txt", "https://example.com/a", "a/b.txt"} {
+		remote, url, err = ufs.ResolveRemote(unchanged)
+		a.NoError(err)
+		a.Nil(remote)
+		a.Equal(unchanged, url)
+	}
+	a.False(ufs.IsRemote("unknown:a/b.txt"))
+	a.False(ufs.IsRemote(`C:\dir\a.txt`))
+	a.False(ufs.IsRemote("a/b.txt"))
+}
+
+func Test_WorkDir_colon(t *testing.T) {
+	a := require.New(t)
+	t.Setenv(ufs.RemotesFileEnv, filepath.Join(t.TempDir(), "missing.yaml"))
+
+	ufs.RegisterRemote("Test_WorkDir_colon", &ufs.RemoteT{Url: "https://example.com/pub"})
+	t.Cleanup(func() { ufs.RegisterRemote("Test_WorkDir_colon", nil) })
+	a.Equal("/default", ufs.WorkDir("Test_WorkDir_colon:a/b.txt", "/default"))
+
+	// the local paths with colon
+	for _, local := range []string{"build:x/out.txt", "my-dir:foo/file"} {
+		a.False(ufs.IsRemote(local), local)
+		a.Equal(filepath.Join("/default", filepath.Dir(local)), ufs.WorkDir(local, "/default"), local)
+	}
+	a.False(ufs.IsRemote("2024-01-01T10:00:00.log"))
+	a.Equal("/default", ufs.WorkDir("2024-01-01T10:00:00.log", "/default"))
+	a.Equal("/logs", ufs.WorkDir("/logs/2024-01-01T10:00:00.log", "/default"))
+}
+
+func Test_NewFile_remote(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+	t.Setenv(ufs.RemotesFileEnv, filepath.Join(t.TempDir(), "missing.yaml"))
+
+	server := startSftpServer(t, "tester", "secret")
+	dir := t.TempDir()
+	ufs.MkdirP(afero.NewOsFs(), filepath.Join(dir, "sub"))
+	ufs.WriteTextP(afero.NewOsFs(), filepath.Join(dir, "sub", "a.txt"), "hello remote")
+
+	ufs.RegisterRemote("sftp-test", &ufs.RemoteT{
+		Url:         "sftp://" + server.Addr + filepath.ToSlash(dir),
+		Credentials: server.Credentials("secret"),
+		Timeout:     7 * time.Second,
+		S3:          &ufs.S3ConfigT{Region: "eu-west-1"},
+	})
+	t.Cleanup(func() { ufs.RegisterRemote("sftp-test", nil) })
+
+	f := ufs.NewFileP(nil, "sftp-test:sub/a.txt", nil, 0)
+	a.Equal("sftp://"+server.Addr+filepath.ToSlash(dir)+"/sub/a.txt", f.Url())
+	a.Equal("tester", f.Credentials().User)
+	a.Equal(7*time.Second, f.Timeout())
+	a.Equal("eu-west-1", f.Options().S3.Region)
+
+	a.Equal("hello remote", ufs.DownloadTextP(nil, "", nil, "sftp-test:sub/a.txt", nil, 0))
+
+	// the specified ones take precedence
+	f = ufs.NewFileP(nil, "sftp-test:sub/a.txt", server.Credentials("wrong"), 3*time.Second, ufs.WithS3(&ufs.S3ConfigT{Region: "us-west-2"}))
+	a.Equal(3*time.Second, f.Timeout())
+	a.Equal("us-west-2", f.Options().S3.Region)
+	_, err := f.Download()
+	a.Error(err)
+}
+
+func Test_NewFile_remotesFile(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "alice" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "hello "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	remotesFile := filepath.Join(t.TempDir(), "remotes.yaml")
+	t.Setenv(ufs.RemotesFileEnv, remotesFile)
+	fs := afero.NewOsFs()
+
+	_, err := ufs.DownloadText(nil, "", nil, "web:a.txt", nil, 3*time.Second)
+	a.Error(err)
+
+	ufs.WriteTextP(fs, remotesFile, "web:\n  url: "+server.URL+"/pub\n  user: alice\n  password: secret\n")
+	a.Equal("hello /pub/a.txt", ufs.DownloadTextP(nil, "", nil, "web:a.txt", nil, 3*time.Second))
+	a.True(ufs.IsRemote("web:a.txt"))
+
+	// reloaded once modified
+	ufs.WriteTextP(fs, remotesFile, "web:\n  url: "+server.URL+"/www\n  user: alice\n  password: secret\n")
+	later := time.Now().Add(time.Minute)
+	a.NoError(os.Chtimes(remotesFile, later, later))
+	a.Equal("hello /www/a.txt", ufs.DownloadTextP(nil, "", nil, "web:a.txt", nil, 3*time.Second))
+
+	ufs.WriteTextP(fs, remotesFile, "web: [")
+	_, err = ufs.DownloadText(nil, "", nil, "web:a.txt", nil, 3*time.Second)
+	a.ErrorContains(err, "parse yaml")
+	_, err = ufs.NewFile(nil, "web:a.txt", nil, 0)
+	a.ErrorContains(err, "parse yaml")
+	_, _, err = ufs.ResolveRemote("web:a.txt")
+	a.ErrorContains(err, "parse yaml")
+	a.False(ufs.IsRemote("web:a.txt"))
+}
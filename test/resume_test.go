@@ -0,0 +1,175 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DownloadResumable_resume(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var requests atomic.Int32
+	var lastRange atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Method == http.MethodGet && requests.Add(1) == 1 {
+			// drop the connection after the first 4 bytes
+			w.Header().Set("Content-Length", "10")
+			io.WriteString(w, "0123")
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		lastRange.Store(r.Header.Get("Range"))
+		http.ServeContent(w, r, "big.bin", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+	f := ufs.NewFileP(nil, server.URL+"/big.bin", nil, 3*time.Second)
+
+	_, err := ufs.DownloadResumable(context.Background(), f, fs, "/big.bin")
+	a.Error(err)
+	a.Equal("0123", ufs.ReadTextP(fs, "/big.bin.part"))
+
+	n := ufs.DownloadResumableP(context.Background(), f, fs, "/big.bin")
+	a.Equal(int64(10), n)
+	a.Equal("bytes=4-", lastRange.Load())
+	a.Equal("0123456789", ufs.ReadTextP(fs, "/big.bin"))
+	a.False(ufs.FileExistsP(fs, "/big.bin.part"))
+	a.False(ufs.FileExistsP(fs, "/big.bin.part.yaml"))
+}
+
+func Test_DownloadResumable_changed(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var lastRange atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		lastRange.Store(r.Header.Get("Range"))
+		http.ServeContent(w, r, "big.bin", time.Time{}, strings.NewReader("abcdefghij"))
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+	url := server.URL + "/big.bin"
+
+	// a partial file of previous version
+	ufs.WriteTextP(fs, "/big.bin.part", "0123")
+	ufs.WriteTextP(fs, "/big.bin.part.yaml", "url: "+url+"\netag: '\"v1\"'\nsize: 10\n")
+
+	f := ufs.NewFileP(nil, url, nil, 3*time.Second)
+	n := ufs.DownloadResumableP(context.Background(), f, fs, "/big.bin")
+	a.Equal(int64(10), n)
+	a.Equal("", lastRange.Load())
+	a.Equal("abcdefghij", ufs.ReadTextP(fs, "/big.bin"))
+}
+
+func Test_DownloadResumable_verify(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+	key := newMinisignKey(t)
+
+	var lastRange atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big.bin.sig" {
+			io.WriteString(w, key.Sign("0123456789", true, "timestamp:1"))
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		lastRange.Store(r.Header.Get("Range"))
+		http.ServeContent(w, r, "big.bin", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+	url := server.URL + "/big.bin"
+
+	// resumes from a partial file of the same version
+	resume := func(part string, options ...ufs.Option) (int64, error) {
+		ufs.WriteTextP(fs, "/big.bin.part", part)
+		ufs.WriteTextP(fs, "/big.bin.part.yaml", "url: "+url+"\netag: '\"v1\"'\nsize: 10\n")
+		return ufs.DownloadResumable(context.Background(), ufs.NewFileP(nil, url, nil, 3*time.Second, options...), fs, "/big.bin")
+	}
+
+	checksum := ufs.WithChecksum(ufs.ChecksumSHA256, sha256Hex("0123456789"))
+	n, err := resume("0123", checksum)
+	a.NoError(err)
+	a.Equal(int64(10), n)
+	a.Equal("bytes=4-", lastRange.Load())
+	a.Equal("0123456789", ufs.ReadTextP(fs, "/big.bin"))
+
+	// the corrupted partial file is removed, so that the next download restarts
+	_, err = resume("abcd", checksum)
+	var mismatchErr *ufs.ChecksumMismatchError
+	a.True(errors.As(err, &mismatchErr))
+	a.False(ufs.FileExistsP(fs, "/big.bin.part"))
+	a.False(ufs.FileExistsP(fs, "/big.bin.part.yaml"))
+
+	trusted := ufs.WithTrustedKeys(ufs.ParseSignatureKeyP(key.PublicKey()))
+	n, err = resume("0123", trusted)
+	a.NoError(err)
+	a.Equal(int64(10), n)
+	a.Equal("bytes=4-", lastRange.Load())
+
+	_, err = resume("abcd", trusted)
+	var signatureErr *ufs.SignatureError
+	a.True(errors.As(err, &signatureErr))
+	a.False(ufs.FileExistsP(fs, "/big.bin.part"))
+
+	// from the beginning
+	fs.Remove("/big.bin")
+	n, err = ufs.DownloadResumable(context.Background(), ufs.NewFileP(nil, url, nil, 3*time.Second, trusted, checksum), fs, "/big.bin")
+	a.NoError(err)
+	a.Equal(int64(10), n)
+	a.Equal("", lastRange.Load())
+	a.Equal("0123456789", ufs.ReadTextP(fs, "/big.bin"))
+}
+
+func Test_DownloadResumable_aferoFile(t *testing.T) {
+	a := require.New(t)
+	key := newMinisignKey(t)
+
+	fs := afero.NewMemMapFs()
+	ufs.WriteTextP(fs, "/src/big.bin", "0123456789")
+	ufs.WriteTextP(fs, "/src/big.bin.sig", key.Sign("0123456789", true, "timestamp:1"))
+	trusted := ufs.WithTrustedKeys(ufs.ParseSignatureKeyP(key.PublicKey()))
+
+	// from the beginning
+	f := ufs.NewAferoFileP(fs, "/src/big.bin", nil, 0, trusted)
+	n, err := ufs.DownloadResumable(context.Background(), f, fs, "/dest/big.bin")
+	a.NoError(err)
+	a.Equal(int64(10), n)
+	a.Equal("0123456789", ufs.ReadTextP(fs, "/dest/big.bin"))
+	a.True(ufs.FileExistsP(fs, "/src/big.bin"))
+
+	// resumes from the partial file
+	fi := f.StatP()
+	ufs.WriteTextP(fs, "/dest/big.bin.part", "0123")
+	ufs.WriteTextP(fs, "/dest/big.bin.part.yaml", "url: "+f.Url()+"\nlastmod: "+fi.ModTime().UTC().Format(time.RFC3339Nano)+"\nsize: 10\n")
+	n, err = ufs.DownloadResumable(context.Background(), f, fs, "/dest/big.bin")
+	a.NoError(err)
+	a.Equal(int64(10), n)
+	a.Equal("0123456789", ufs.ReadTextP(fs, "/dest/big.bin"))
+	a.True(ufs.FileExistsP(fs, "/src/big.bin"))
+
+	// the verified download keeps the file too
+	c := f.DownloadP()
+	b, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+	a.NoError(c.Blob.Close())
+	a.Equal("0123456789", string(b))
+	a.True(ufs.FileExistsP(fs, "/src/big.bin"))
+}
@@ -0,0 +1,187 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/stretchr/testify/require"
+)
+
+func fastRetry() ufs.RetryPolicy {
+	return &ufs.RetryPolicyT{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, Multiplier: 2}
+}
+
+func Test_RetryPolicy_Backoff(t *testing.T) {
+	a := require.New(t)
+
+	policy := &ufs.RetryPolicyT{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 3}
+	a.Equal(100*time.Millisecond, policy.Backoff(1))
+	a.Equal(300*time.Millisecond, policy.Backoff(2))
+	a.Equal(900*time.Millisecond, policy.Backoff(3))
+	a.Equal(time.Second, policy.Backoff(4))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		backoff := policy.Backoff(1)
+		a.True(backoff > 50*time.Millisecond && backoff <= 100*time.Millisecond)
+	}
+}
+
+func Test_Retry_Http_5xx(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "Test_Retry_Http_5xx")
+	}))
+	defer server.Close()
+
+	text := ufs.DownloadTextP(nil, "", nil, server.URL+"/test.txt", nil, 3*time.Second, ufs.WithRetry(fastRetry()))
+	a.Equal("Test_Retry_Http_5xx", text)
+	a.Equal(int32(3), requests.Load())
+}
+
+func Test_Retry_Http_giveUp(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := ufs.DownloadText(nil, "", nil, server.URL+"/test.txt", nil, 3*time.Second, ufs.WithRetry(fastRetry()))
+	a.ErrorContains(err, "502")
+	a.Equal(int32(3), requests.Load())
+
+	requests.Store(0)
+	_, err = ufs.DownloadText(nil, "", nil, server.URL+"/test.txt", nil, 3*time.Second, ufs.WithRetry(ufs.NoRetry))
+	a.Error(err)
+	a.Equal(int32(1), requests.Load())
+}
+
+func Test_Retry_Http_notRetryable(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	f := ufs.NewFileP(nil, server.URL+"/test.txt", nil, 3*time.Second, ufs.WithRetry(fastRetry()))
+	_, err := f.Download()
+	a.Error(err)
+	a.Equal(int32(1), requests.Load())
+}
+
+func Test_Retry_Http_RetryAfter(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	// the server asks to wait longer than the max backoff, so gives up
+	f := ufs.NewFileP(nil, server.URL+"/test.txt", nil, 3*time.Second, ufs.WithRetry(fastRetry()))
+	_, err := f.Stat()
+	a.Error(err)
+	a.Equal(int32(1), requests.Load())
+
+	var statusErr *ufs.HttpStatusError
+	a.ErrorAs(err, &statusErr)
+	a.Equal(http.StatusTooManyRequests, statusErr.StatusCode)
+	a.Equal(60*time.Second, statusErr.RetryAfter)
+}
+
+func Test_Retry_Http_Upload(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+
+	var requests atomic.Int32
+	var body atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body.Store(string(b))
+	}))
+	defer server.Close()
+
+	f := ufs.NewFileP(nil, server.URL+"/test.txt", nil, 3*time.Second, ufs.WithRetry(fastRetry()))
+	n := f.UploadP(strings.NewReader("Test_Retry_Http_Upload"))
+	a.Equal(int64(22), n)
+	a.Equal(int32(2), requests.Load())
+	a.Equal("Test_Retry_Http_Upload", body.Load())
+}
+
+func Test_IsTransientError(t *testing.T) {
+	a := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	_, err := http.Get(url)
+	a.True(ufs.IsTransientError(err))
+	a.False(ufs.IsTransientError(io.EOF))
+	a.True(ufs.IsTransientError(io.ErrUnexpectedEOF))
+}
+
+func Test_DefaultRetryPolicy(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+	a.Equal(3, ufs.DefaultRetryPolicy().MaxAttempts)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/missing":
+			requests.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		case requests.Add(1) == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			io.WriteString(w, "hello")
+		}
+	}))
+	defer server.Close()
+
+	// the transient failure is retried by default
+	a.Equal("hello", ufs.DownloadTextP(nil, "", nil, server.URL+"/a.txt", nil, 0))
+	a.Equal(int32(2), requests.Load())
+
+	// but not the others
+	requests.Store(0)
+	_, err := ufs.NewFileP(nil, server.URL+"/missing", nil, 0).Download()
+	a.Error(err)
+	a.Equal(int32(1), requests.Load())
+
+	// nor with NoRetry
+	requests.Store(0)
+	_, err = ufs.NewFileP(nil, server.URL+"/a.txt", nil, 0, ufs.WithRetry(ufs.NoRetry)).Download()
+	a.Error(err)
+	a.Equal(int32(1), requests.Load())
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func newS3Fs(t *testing.T, bucketURL string) (*s3Server, ufs.S3Fs) {
+	server, endpoint := startS3Server(t, "test")
+
+	cred := &ufs.CredentialsT{User: "key", Password: "secret"}
+	fs := ufs.NewS3FsP(bucketURL, cred, ufs.WithS3(&ufs.S3ConfigT{Endpoint: endpoint, Region: "us-east-1"}))
+	return server, fs
+}
+
+func Test_S3Fs_helpers(t *testing.T) {
+	a := require.New(t)
+	server, fs := newS3Fs(t, "s3://minio/test/app")
+
+	ufs.WriteTextP(fs, "/conf/x.yaml", "name: x")
+	ufs.WriteTextP(fs, "/conf/y.yaml", "name: y")
+	ufs.WriteTextP(fs, "/conf/z.txt", "z")
+	a.Equal([]string{"app/conf/x.yaml", "app/conf/y.yaml", "app/conf/z.txt"}, server.Keys("test"))
+
+	a.Equal("name: x", ufs.ReadTextP(fs, "/conf/x.yaml"))
+	a.Equal(map[string]string{"x": "/conf/x.yaml", "y": "/conf/y.yaml"}, ufs.ListSuffixedP(fs, "/conf", ".yaml", false))
+
+	m := ufs.MapFromYamlFileP(fs, "/conf/y.yaml", false)
+	a.Equal("y", m["name"])
+
+	a.True(ufs.DirExistsP(fs, "/conf"))
+	a.True(ufs.FileExistsP(fs, "/conf/z.txt"))
+	a.False(ufs.FileExistsP(fs, "/conf/missing.txt"))
+}
+
+func Test_S3Fs_Stat(t *testing.T) {
+	a := require.New(t)
+	server, fs := newS3Fs(t, "s3://minio/test")
+
+	ufs.WriteTextP(fs, "/a/b/c.txt", "hello")
+
+	fi, err := fs.Stat("/a/b/c.txt")
+	a.NoError(err)
+	a.Equal("c.txt", fi.Name())
+	a.Equal(int64(5), fi.Size())
+	a.False(fi.IsDir())
+	a.NotEmpty(fi.(ufs.RemoteFileInfo).ETag)
+	a.Contains(server.Requests(), "HEAD /test/a/b/c.txt")
+
+	for _, dir := range []string{"/", "/a", "/a/b"} {
+		fi, err = fs.Stat(dir)
+		a.NoError(err)
+		a.True(fi.IsDir(), dir)
+	}
+
+	_, err = fs.Stat("/a/b/missing")
+	a.True(os.IsNotExist(err))
+}
+
+func Test_S3Fs_dirs(t *testing.T) {
+	a := require.New(t)
+	server, fs := newS3Fs(t, "s3://minio/test")
+
+	a.NoError(fs.Mkdir("/empty", 0o755))
+	a.True(os.IsExist(fs.Mkdir("/empty", 0o755)))
+	a.NoError(fs.MkdirAll("/x/y", 0o755))
+	a.NoError(fs.MkdirAll("/x/y", 0o755))
+	a.Equal([]string{"empty/", "x/y/"}, server.Keys("test"))
+
+	ufs.WriteTextP(fs, "/x/1.txt", "1")
+	ufs.WriteTextP(fs, "/x/y/2.txt", "2")
+
+	entries, err := afero.ReadDir(fs, "/x")
+	a.NoError(err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	a.Equal([]string{"1.txt", "y"}, names)
+
+	entries, err = afero.ReadDir(fs, "/empty")
+	a.NoError(err)
+	a.Empty(entries)
+
+	a.Error(fs.Remove("/x"))
+	a.NoError(fs.Remove("/empty"))
+	a.NoError(fs.RemoveAll("/x"))
+	a.NoError(fs.RemoveAll("/x"))
+	a.Empty(server.Keys("test"))
+}
+
+func Test_S3Fs_Rename(t *testing.T) {
+	a := require.New(t)
+	server, fs := newS3Fs(t, "s3://minio/test")
+
+	ufs.WriteTextP(fs, "/old.txt", "old")
+	a.NoError(fs.Rename("/old.txt", "/new.txt"))
+	a.Equal("old", ufs.ReadTextP(fs, "/new.txt"))
+
+	ufs.WriteTextP(fs, "/dir/a.txt", "a")
+	ufs.WriteTextP(fs, "/dir/sub/b.txt", "b")
+	a.NoError(fs.Rename("/dir", "/moved"))
+	a.Equal([]string{"moved/a.txt", "moved/sub/b.txt", "new.txt"}, server.Keys("test"))
+
+	a.True(os.IsNotExist(fs.Rename("/missing", "/x")))
+}
+
+func Test_S3Fs_multipart(t *testing.T) {
+	a := require.New(t)
+	server, fs := newS3Fs(t, "s3://minio/test")
+
+	// larger than the minimal part size (5MB)
+	data := bytes.Repeat([]byte("0123456789"), 600*1024)
+
+	f, err := fs.Create("/big.bin")
+	a.NoError(err)
+	_, err = f.Write(data)
+	a.NoError(err)
+	a.NoError(f.Close())
+	a.Contains(server.Requests(), "POST /test/big.bin?uploads")
+
+	r, err := fs.Open("/big.bin")
+	a.NoError(err)
+	defer r.Close()
+
+	buf := make([]byte, 10)
+	_, err = r.ReadAt(buf, 5*1024*1024+3)
+	a.NoError(err)
+	a.Equal("3456789012", string(buf))
+
+	all, err := io.ReadAll(r)
+	a.NoError(err)
+	a.Equal(data, all)
+}
+
+func Test_S3Fs_OpenFile(t *testing.T) {
+	a := require.New(t)
+	_, fs := newS3Fs(t, "s3://minio/test")
+
+	_, err := fs.OpenFile("/x.txt", os.O_WRONLY, 0)
+	a.True(os.IsNotExist(err))
+
+	ufs.WriteTextP(fs, "/x.txt", "x")
+	_, err = fs.OpenFile("/x.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	a.True(os.IsExist(err))
+
+	_, err = fs.OpenFile("/x.txt", os.O_WRONLY|os.O_APPEND, 0)
+	a.ErrorIs(err, ufs.ErrNotSupported)
+
+	a.ErrorIs(fs.Chmod("/x.txt", 0o600), ufs.ErrNotSupported)
+
+	_, err = ufs.NewS3Fs("s3://minio/", nil)
+	a.ErrorContains(err, "bucket not specified")
+}
@@ -0,0 +1,260 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func newS3File(t *testing.T, url string, options ...ufs.Option) (*s3Server, ufs.File) {
+	server, endpoint := startS3Server(t, "test")
+
+	cred := &ufs.CredentialsT{User: "key", Password: "secret"}
+	options = append(options, ufs.WithS3(&ufs.S3ConfigT{Endpoint: endpoint, Region: "us-east-1"}))
+	return server, ufs.NewFileP(nil, url, cred, 5*time.Second, options...)
+}
+
+func Test_S3Protocol_happy(t *testing.T) {
+	a := require.New(t)
+	server, f := newS3File(t, "s3://minio/test/hello.txt")
+
+	n := f.UploadP(strings.NewReader("hello s3"))
+	a.Equal(int64(8), n)
+	a.Equal([]string{"hello.txt"}, server.Keys("test"))
+
+	fi := f.StatP()
+	a.Equal(int64(8), fi.Size())
+	a.True(f.ExistsP())
+
+	c := f.DownloadP()
+	b, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+	a.NoError(c.Blob.Close())
+	a.Equal("hello s3", string(b))
+
+	r := f.OpenRandomP()
+	buf := make([]byte, 2)
+	_, err = r.ReadAt(buf, 6)
+	a.NoError(err)
+	a.Equal("s3", string(buf))
+	a.NoError(r.Close())
+
+	f.RemoveP()
+	a.False(f.ExistsP())
+	_, err = f.Stat()
+	a.True(os.IsNotExist(err))
+}
+
+func Test_S3Protocol_notFound(t *testing.T) {
+	a := require.New(t)
+	server, f := newS3File(t, "s3://minio/test/missing.txt")
+
+	// HeadObject
+	_, err := f.Stat()
+	a.ErrorIs(err, os.ErrNotExist)
+	a.Contains(server.Requests(), "HEAD /test/missing.txt")
+	a.False(f.ExistsP())
+
+	// GetObject answers NoSuchKey
+	_, err = f.Download()
+	a.ErrorIs(err, os.ErrNotExist)
+	a.Contains(server.Requests(), "GET /test/missing.txt")
+
+	_, err = f.OpenRandom()
+	a.ErrorIs(err, os.ErrNotExist)
+
+	// NoSuchBucket
+	_, other := newS3File(t, "s3://minio/missing/a.txt")
+	_, err = other.Download()
+	a.ErrorIs(err, os.ErrNotExist)
+
+	// DeleteObject
+	server.Put("test", "a.txt", []byte("a"))
+	ufs.NewFileP(nil, "s3://minio/test/a.txt", f.Credentials(), 5*time.Second, ufs.WithS3(f.Options().S3)).RemoveP()
+	a.Contains(server.Requests(), "DELETE /test/a.txt")
+	a.Empty(server.Keys("test"))
+}
+
+func Test_S3Protocol_Download_tempFile(t *testing.T) {
+	a := require.New(t)
+	_, f := newS3File(t, "s3://minio/test/hello.txt", ufs.WithTempFile())
+
+	f.UploadP(strings.NewReader("hello s3"))
+
+	c := f.DownloadP()
+	b, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+	a.NoError(c.Blob.Close())
+	a.Equal("hello s3", string(b))
+}
+
+// isolateAws clears the AWS environment, so that the tests do not pick up the real credentials and config
+func isolateAws(t *testing.T) {
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_PROFILE"} {
+		t.Setenv(name, "")
+	}
+	missing := filepath.Join(t.TempDir(), "missing")
+	t.Setenv("AWS_CONFIG_FILE", missing)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", missing)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+func Test_S3Protocol_config(t *testing.T) {
+	a := require.New(t)
+	isolateAws(t)
+
+	server, endpoint := startS3Server(t, "test")
+	config := &ufs.S3ConfigT{Endpoint: endpoint, Region: "eu-west-1", SessionToken: "token", ServerSideEncryption: "AES256"}
+	f := ufs.NewFileP(nil, "s3://minio/test/hello.txt", &ufs.CredentialsT{User: "key", Password: "secret"}, 5*time.Second, ufs.WithS3(config))
+
+	f.UploadP(strings.NewReader("hello"))
+	header := server.Header("PUT /test/hello.txt")
+	a.Equal("token", header.Get("X-Amz-Security-Token"))
+	a.Equal("AES256", header.Get("X-Amz-Server-Side-Encryption"))
+	a.Contains(header.Get("Authorization"), "Credential=key/")
+	a.Contains(header.Get("Authorization"), "/eu-west-1/s3/")
+
+	config.Anonymous = true
+	a.True(f.ExistsP())
+	a.Empty(server.Header("HEAD /test/hello.txt").Get("Authorization"))
+}
+
+func Test_S3Protocol_RegisterS3Config(t *testing.T) {
+	a := require.New(t)
+	isolateAws(t)
+	t.Setenv("AWS_REGION", "eu-west-2")
+
+	server, endpoint := startS3Server(t, "test")
+	ufs.RegisterS3Config("Minio", &ufs.S3ConfigT{Endpoint: endpoint})
+	t.Cleanup(func() { ufs.RegisterS3Config("minio", nil) })
+	a.NotNil(ufs.LookupS3Config("minio"))
+
+	f := ufs.NewFileP(nil, "s3://minio:9000/test/hello.txt", &ufs.CredentialsT{User: "key", Password: "secret"}, 5*time.Second)
+	f.UploadP(strings.NewReader("hello"))
+	a.Equal([]string{"hello.txt"}, server.Keys("test"))
+	a.Contains(server.Header("PUT /test/hello.txt").Get("Authorization"), "/eu-west-2/s3/")
+}
+
+func Test_S3Protocol_credentialsChain(t *testing.T) {
+	a := require.New(t)
+	isolateAws(t)
+
+	server, endpoint := startS3Server(t, "test")
+	options := ufs.WithS3(&ufs.S3ConfigT{Endpoint: endpoint})
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "envkey")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envsecret")
+	ufs.NewFileP(nil, "s3://minio/test/env.txt", nil, 5*time.Second, options).UploadP(strings.NewReader("env"))
+	a.Contains(server.Header("PUT /test/env.txt").Get("Authorization"), "Credential=envkey/")
+	a.Contains(server.Header("PUT /test/env.txt").Get("Authorization"), "/"+ufs.S3DefaultRegion+"/s3/")
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	credentialsFile := filepath.Join(t.TempDir(), "credentials")
+	ufs.WriteTextP(afero.NewOsFs(), credentialsFile, "[dev]\naws_access_key_id = profilekey\naws_secret_access_key = profilesecret\n")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+
+	options = ufs.WithS3(&ufs.S3ConfigT{Endpoint: endpoint, Profile: "dev"})
+	ufs.NewFileP(nil, "s3://minio/test/profile.txt", nil, 5*time.Second, options).UploadP(strings.NewReader("profile"))
+	a.Contains(server.Header("PUT /test/profile.txt").Get("Authorization"), "Credential=profilekey/")
+}
+
+func Test_S3Protocol_credentialsChain_notOverridden(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+	isolateAws(t)
+
+	server, endpoint := startS3Server(t, "test")
+	fs := afero.NewOsFs()
+	home := t.TempDir()
+
+	// neither the netrc default nor the ufs credentials are taken as the aws keys
+	netrc := filepath.Join(home, "netrc")
+	ufs.WriteTextP(fs, netrc, "default login bogus password bogus\n")
+	t.Setenv("NETRC", netrc)
+
+	credentialsFile := filepath.Join(home, "credentials")
+	ufs.WriteTextP(fs, credentialsFile, "[default]\naws_access_key_id = filekey\naws_secret_access_key = filesecret\n[dev]\naws_access_key_id = devkey\naws_secret_access_key = devsecret\n")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+
+	f := ufs.NewFileP(nil, "s3://minio/test/a.txt", nil, 5*time.Second, ufs.WithS3(&ufs.S3ConfigT{Endpoint: endpoint}))
+	a.Empty(f.Credentials().User)
+	f.UploadP(strings.NewReader("a"))
+	a.Contains(server.Header("PUT /test/a.txt").Get("Authorization"), "Credential=filekey/")
+
+	// the environment goes before the shared credentials file
+	t.Setenv("AWS_ACCESS_KEY_ID", "envkey")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envsecret")
+	ufs.NewFileP(nil, "s3://minio/test/env.txt", nil, 5*time.Second, ufs.WithS3(&ufs.S3ConfigT{Endpoint: endpoint})).UploadP(strings.NewReader("env"))
+	a.Contains(server.Header("PUT /test/env.txt").Get("Authorization"), "Credential=envkey/")
+
+	// the profile specified by option
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	ufs.NewFileP(nil, "s3://minio/test/dev.txt", nil, 5*time.Second, ufs.WithS3(&ufs.S3ConfigT{Endpoint: endpoint, Profile: "dev"})).UploadP(strings.NewReader("dev"))
+	a.Contains(server.Header("PUT /test/dev.txt").Get("Authorization"), "Credential=devkey/")
+}
+
+func Test_S3Protocol_nestedKey(t *testing.T) {
+	a := require.New(t)
+	server, f := newS3File(t, "s3://minio/test/a/b/c.yaml")
+
+	f.UploadP(strings.NewReader("name: c"))
+	a.Equal([]string{"a/b/c.yaml"}, server.Keys("test"))
+
+	fi := f.StatP()
+	a.Equal("c.yaml", fi.Name())
+	a.Equal(int64(7), fi.Size())
+	a.Contains(server.Requests(), "HEAD /test/a/b/c.yaml")
+	for _, request := range server.Requests() {
+		a.False(strings.HasPrefix(request, "GET /test?"), request)
+	}
+
+	c := f.DownloadP()
+	b, err := io.ReadAll(c.Blob)
+	a.NoError(err)
+	a.NoError(c.Blob.Close())
+	a.Equal("name: c", string(b))
+
+	cred := &ufs.CredentialsT{User: "key", Password: "secret"}
+	dir := ufs.NewFileP(nil, "s3://minio/test/a", cred, 5*time.Second, ufs.WithS3(f.Options().S3))
+	a.True(dir.StatP().IsDir())
+
+	entries := dir.ListP()
+	a.Len(entries, 1)
+	a.Equal("b", entries[0].Name())
+	a.True(entries[0].IsDir())
+}
+
+func Test_S3Protocol_List_paginated(t *testing.T) {
+	a := require.New(t)
+	server, dir := newS3File(t, "s3://minio/test/many")
+
+	for i := 0; i < 1005; i++ {
+		server.Put("test", fmt.Sprintf("many/%04d.txt", i), []byte("x"))
+	}
+	server.Put("test", "many/sub/x.txt", []byte("x"))
+	server.Put("test", "other.txt", []byte("x"))
+
+	entries := dir.ListP()
+	a.Len(entries, 1006)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	a.Equal("0000.txt", names[0])
+	a.Equal("1004.txt", names[1004])
+	a.Equal("sub", names[1005])
+	a.Contains(server.Requests(), "GET /test?continuation-token&delimiter&list-type&prefix")
+}
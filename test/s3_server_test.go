@@ -0,0 +1,297 @@
+package test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type s3Object struct {
+	data    []byte
+	etag    string
+	lastmod time.Time
+}
+
+// s3Server is an in-memory stand-in of S3-compatible object store, i.e, MinIO, with path-style addressing.
+// It serves just enough of the S3 API for the tests: objects, copy, multipart upload and ListObjectsV2.
+type s3Server struct {
+	mutex   sync.Mutex
+	buckets map[string]map[string]*s3Object
+	uploads map[string]map[int][]byte
+	nextId  int
+
+	// the requests received, i.e. "PUT /bucket/key?partNumber"
+	requests []string
+	// the headers of the latest request by the request line
+	headers map[string]http.Header
+}
+
+// startS3Server starts the S3 stand-in with the given empty buckets, and returns its endpoint url
+func startS3Server(t *testing.T, buckets ...string) (*s3Server, string) {
+	r := &s3Server{buckets: map[string]map[string]*s3Object{}, uploads: map[string]map[int][]byte{}, headers: map[string]http.Header{}}
+	for _, bucket := range buckets {
+		r.buckets[bucket] = map[string]*s3Object{}
+	}
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return r, server.URL
+}
+
+func (me *s3Server) put(bucket string, key string, data []byte) *s3Object {
+	sum := md5.Sum(data)
+	obj := &s3Object{data: data, etag: `"` + hex.EncodeToString(sum[:]) + `"`, lastmod: time.Now().UTC().Truncate(time.Second)}
+	me.buckets[bucket][key] = obj
+	return obj
+}
+
+// Put puts the object directly
+func (me *s3Server) Put(bucket string, key string, data []byte) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	me.put(bucket, key, data)
+}
+
+func (me *s3Server) Keys(bucket string) []string {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	var r []string
+	for key := range me.buckets[bucket] {
+		r = append(r, key)
+	}
+	sort.Strings(r)
+	return r
+}
+
+func (me *s3Server) Requests() []string {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return append([]string{}, me.requests...)
+}
+
+// Header returns the headers of the latest request, i.e, "PUT /bucket/key"
+func (me *s3Server) Header(request string) http.Header {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.headers[request]
+}
+
+func (me *s3Server) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (me *s3Server) xml(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	b, _ := xml.Marshal(v)
+	w.Write(b)
+}
+
+func (me *s3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	query := r.URL.Query()
+	var queryKeys []string
+	for k := range query {
+		queryKeys = append(queryKeys, k)
+	}
+	sort.Strings(queryKeys)
+	request := strings.TrimSuffix(r.Method+" "+r.URL.Path+"?"+strings.Join(queryKeys, "&"), "?")
+	me.requests = append(me.requests, request)
+	me.headers[request] = r.Header.Clone()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	objects, found := me.buckets[bucket]
+	if !found {
+		me.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	if key == "" {
+		if r.Method == http.MethodGet && query.Get("list-type") == "2" {
+			me.list(w, bucket, objects, query)
+			return
+		}
+		me.error(w, http.StatusNotImplemented, "NotImplemented")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		me.nextId++
+		uploadId := strconv.Itoa(me.nextId)
+		me.uploads[uploadId] = map[int][]byte{}
+		me.xml(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: uploadId})
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, found := me.uploads[query.Get("uploadId")]
+		if !found {
+			me.error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := io.ReadAll(r.Body)
+		parts[partNumber] = data
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		uploadId := query.Get("uploadId")
+		parts, found := me.uploads[uploadId]
+		if !found {
+			me.error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var numbers []int
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var data bytes.Buffer
+		for _, n := range numbers {
+			data.Write(parts[n])
+		}
+		delete(me.uploads, uploadId)
+		obj := me.put(bucket, key, data.Bytes())
+		me.xml(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: obj.etag})
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(me.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		sourceBucket, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+		sourceObj, found := me.buckets[sourceBucket][sourceKey]
+		if !found {
+			me.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		obj := me.put(bucket, key, sourceObj.data)
+		me.xml(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			ETag         string
+			LastModified string
+		}{ETag: obj.etag, LastModified: obj.lastmod.Format(time.RFC3339)})
+
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		obj := me.put(bucket, key, data)
+		w.Header().Set("ETag", obj.etag)
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, found := objects[key]
+		if !found {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+			} else {
+				me.error(w, http.StatusNotFound, "NoSuchKey")
+			}
+			return
+		}
+		w.Header().Set("ETag", obj.etag)
+		http.ServeContent(w, r, key, obj.lastmod, bytes.NewReader(obj.data))
+
+	case r.Method == http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		me.error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+type s3ListContents struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+}
+
+type s3ListPrefix struct {
+	Prefix string
+}
+
+func (me *s3Server) list(w http.ResponseWriter, bucket string, objects map[string]*s3Object, query url.Values) {
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	token := query.Get("continuation-token")
+	maxKeys := 1000
+	if s := query.Get("max-keys"); s != "" {
+		maxKeys, _ = strconv.Atoi(s)
+	}
+
+	var keys []string
+	for key := range objects {
+		if strings.HasPrefix(key, prefix) && key > token {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		Delimiter             string
+		MaxKeys               int
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string           `xml:",omitempty"`
+		Contents              []s3ListContents `xml:"Contents"`
+		CommonPrefixes        []s3ListPrefix   `xml:"CommonPrefixes"`
+	}{Name: bucket, Prefix: prefix, Delimiter: delimiter, MaxKeys: maxKeys}
+
+	for i := 0; i < len(keys); i++ {
+		if result.KeyCount >= maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = keys[i-1]
+			break
+		}
+
+		key := keys[i]
+		rest := key[len(prefix):]
+		if pos := strings.Index(rest, delimiter); delimiter != "" && pos >= 0 {
+			commonPrefix := prefix + rest[:pos+len(delimiter)]
+			result.CommonPrefixes = append(result.CommonPrefixes, s3ListPrefix{Prefix: commonPrefix})
+			for i+1 < len(keys) && strings.HasPrefix(keys[i+1], commonPrefix) {
+				i++
+			}
+		} else {
+			obj := objects[key]
+			result.Contents = append(result.Contents, s3ListContents{
+				Key:          key,
+				LastModified: obj.lastmod.Format(time.RFC3339),
+				ETag:         obj.etag,
+				Size:         len(obj.data),
+			})
+		}
+		result.KeyCount++
+	}
+
+	me.xml(w, result)
+}
@@ -0,0 +1,269 @@
+package test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func readFile(t *testing.T, path string) string {
+	r, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(r)
+}
+
+func sftpStat(server sftpServer, cred ufs.Credentials) error {
+	_, err := ufs.NewFileP(nil, "sftp://"+server.Addr+"/", cred, 5*time.Second).Stat()
+	return err
+}
+
+// startPublicKeySftpServer accepts only the public key of the signer
+func startPublicKeySftpServer(t *testing.T, signer ssh.Signer) sftpServer {
+	want := signer.PublicKey().Marshal()
+	return startSftpServerWith(t, "tester", &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == "tester" && string(key.Marshal()) == string(want) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("public key rejected for %q", c.User())
+		},
+	})
+}
+
+func newSshKey(t *testing.T, key crypto.Signer, passphrase string) (ssh.Signer, string) {
+	a := require.New(t)
+
+	signer, err := ssh.NewSignerFromKey(key)
+	a.NoError(err)
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	}
+	a.NoError(err)
+	return signer, string(pem.EncodeToMemory(block))
+}
+
+func newEd25519Key(t *testing.T, passphrase string) (ssh.Signer, string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return newSshKey(t, key, passphrase)
+}
+
+func Test_SftpAuth_pinnedFingerprint(t *testing.T) {
+	a := require.New(t)
+	server := startSftpServer(t, "tester", "secret")
+
+	a.NoError(sftpStat(server, server.Credentials("secret")))
+
+	sum := md5.Sum(server.HostKey.Marshal())
+	legacy := []string{}
+	for _, b := range sum {
+		legacy = append(legacy, hex.EncodeToString([]byte{b}))
+	}
+	cred := &ufs.CredentialsT{User: "tester", Password: "secret", HostKeyFingerprint: "MD5:" + strings.Join(legacy, ":")}
+	a.NoError(sftpStat(server, cred))
+
+	other := startSftpServer(t, "tester", "secret")
+	cred = &ufs.CredentialsT{User: "tester", Password: "secret", HostKeyFingerprint: other.Fingerprint}
+	a.ErrorContains(sftpStat(server, cred), "does not match the pinned fingerprint")
+}
+
+func Test_SftpAuth_knownHosts(t *testing.T) {
+	a := require.New(t)
+	server := startSftpServer(t, "tester", "secret")
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	cred := &ufs.CredentialsT{User: "tester", Password: "secret", KnownHostsFile: knownHostsFile}
+
+	// strict by default: the missing known_hosts file knows no host
+	a.ErrorContains(sftpStat(server, cred), "is unknown")
+	a.NoFileExists(knownHostsFile)
+
+	a.NoError(os.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{server.Addr}, server.HostKey)+"\n"), 0o600))
+	a.NoError(sftpStat(server, cred))
+
+	other := startSftpServer(t, "tester", "secret")
+	a.NoError(os.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{server.Addr}, other.HostKey)+"\n"), 0o600))
+	a.ErrorContains(sftpStat(server, cred), "does not match")
+}
+
+func Test_SftpAuth_knownHosts_keyAlgorithm(t *testing.T) {
+	a := require.New(t)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.NoError(err)
+	ecdsaSigner, err := ssh.NewSignerFromKey(ecdsaKey)
+	a.NoError(err)
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(ecdsaSigner)
+	server := startSftpServerWith(t, "tester", config)
+
+	// the server prefers ecdsa, but the known key is ed25519
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	a.NoError(os.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{server.Addr}, server.HostKey)+"\n"), 0o600))
+
+	a.NoError(sftpStat(server, &ufs.CredentialsT{User: "tester", KnownHostsFile: knownHostsFile}))
+}
+
+func Test_SftpAuth_trustOnFirstUse(t *testing.T) {
+	a := require.New(t)
+	server := startSftpServer(t, "tester", "secret")
+	knownHostsFile := filepath.Join(t.TempDir(), ".ssh", "known_hosts")
+	cred := &ufs.CredentialsT{User: "tester", Password: "secret", KnownHostsFile: knownHostsFile, HostKeyPolicy: ufs.HostKeyTrustOnFirstUse}
+
+	a.NoError(sftpStat(server, cred))
+	a.Equal(knownhosts.Line([]string{server.Addr}, server.HostKey)+"\n", readFile(t, knownHostsFile))
+
+	a.NoError(sftpStat(server, cred))
+	a.NoError(sftpStat(server, &ufs.CredentialsT{User: "tester", Password: "secret", KnownHostsFile: knownHostsFile}))
+
+	// the known host is not trusted again with a different key
+	other := startSftpServer(t, "tester", "secret")
+	a.NoError(os.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{server.Addr}, other.HostKey)+"\n"), 0o600))
+	a.ErrorContains(sftpStat(server, cred), "does not match")
+}
+
+func Test_SftpAuth_insecure(t *testing.T) {
+	a := require.New(t)
+	server := startSftpServer(t, "tester", "secret")
+
+	cred := &ufs.CredentialsT{User: "tester", Password: "secret", HostKeyPolicy: ufs.HostKeyInsecure}
+	a.NoError(sftpStat(server, cred))
+
+	cred = &ufs.CredentialsT{User: "tester", Password: "secret", HostKeyPolicy: "whatever"}
+	a.ErrorContains(sftpStat(server, cred), "unknown host key policy")
+}
+
+func Test_SftpAuth_ed25519Key(t *testing.T) {
+	a := require.New(t)
+	signer, key := newEd25519Key(t, "")
+	server := startPublicKeySftpServer(t, signer)
+
+	cred := &ufs.CredentialsT{User: "tester", PrivateKey: key, HostKeyFingerprint: server.Fingerprint}
+	a.NoError(sftpStat(server, cred))
+
+	_, otherKey := newEd25519Key(t, "")
+	cred = &ufs.CredentialsT{User: "tester", PrivateKey: otherKey, HostKeyFingerprint: server.Fingerprint}
+	a.ErrorContains(sftpStat(server, cred), "unable to authenticate")
+}
+
+func Test_SftpAuth_ecdsaKeyFile(t *testing.T) {
+	a := require.New(t)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	a.NoError(err)
+	signer, key := newSshKey(t, ecdsaKey, "passphrase")
+	server := startPublicKeySftpServer(t, signer)
+
+	keyFile := filepath.Join(t.TempDir(), "id_ecdsa")
+	a.NoError(os.WriteFile(keyFile, []byte(key), 0o600))
+
+	cred := &ufs.CredentialsT{User: "tester", PrivateKeyFile: keyFile, PrivateKeyPassphrase: "passphrase", HostKeyFingerprint: server.Fingerprint}
+	a.NoError(sftpStat(server, cred))
+
+	cred.PrivateKeyPassphrase = "wrong"
+	a.ErrorContains(sftpStat(server, cred), "parse private key")
+}
+
+func Test_SftpAuth_rsaPrivateKey_deprecated(t *testing.T) {
+	a := require.New(t)
+	signer, key := newEd25519Key(t, "")
+	server := startPublicKeySftpServer(t, signer)
+
+	cred := &ufs.CredentialsT{User: "tester", RsaPrivateKey: key, HostKeyFingerprint: server.Fingerprint}
+	a.NoError(sftpStat(server, cred))
+}
+
+func Test_SftpAuth_sshAgent(t *testing.T) {
+	a := require.New(t)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	a.NoError(err)
+	signer, err := ssh.NewSignerFromKey(key)
+	a.NoError(err)
+	server := startPublicKeySftpServer(t, signer)
+
+	cred := &ufs.CredentialsT{User: "tester", SshAgent: true, HostKeyFingerprint: server.Fingerprint}
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	a.ErrorContains(sftpStat(server, cred), "SSH_AUTH_SOCK is not set")
+
+	keyring := agent.NewKeyring()
+	a.NoError(keyring.Add(agent.AddedKey{PrivateKey: key}))
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", sock)
+	a.NoError(err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	a.NoError(sftpStat(server, cred))
+}
+
+// startChallengeSftpServer accepts only the keyboard-interactive answer to the question
+func startChallengeSftpServer(t *testing.T, question string, answer string) sftpServer {
+	return startSftpServerWith(t, "tester", &ssh.ServerConfig{
+		KeyboardInteractiveCallback: func(c ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge(c.User(), "", []string{question}, []bool{false})
+			if err != nil {
+				return nil, err
+			}
+			if c.User() == "tester" && answers[0] == answer {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("keyboard-interactive rejected for %q", c.User())
+		},
+	})
+}
+
+func Test_SftpAuth_keyboardInteractive_password(t *testing.T) {
+	a := require.New(t)
+	server := startChallengeSftpServer(t, "Password: ", "secret")
+
+	a.NoError(sftpStat(server, server.Credentials("secret")))
+	a.ErrorContains(sftpStat(server, server.Credentials("wrong")), "unable to authenticate")
+}
+
+func Test_SftpAuth_keyboardInteractive(t *testing.T) {
+	a := require.New(t)
+	server := startChallengeSftpServer(t, "Verification code: ", "123456")
+
+	cred := server.Credentials("")
+	cred.KeyboardInteractive = func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		a.Equal([]string{"Verification code: "}, questions)
+		return []string{"123456"}, nil
+	}
+	a.NoError(sftpStat(server, cred))
+}
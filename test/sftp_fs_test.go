@@ -0,0 +1,215 @@
+package test
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func newSftpFs(t *testing.T) (ufs.SftpFs, string) {
+	server := startSftpServer(t, "tester", "secret")
+	root := t.TempDir()
+
+	cred := server.Credentials("secret")
+	fs := ufs.NewSftpFsP("sftp://"+server.Addr+filepath.ToSlash(root), cred, 5*time.Second)
+	t.Cleanup(func() { fs.Close() })
+	return fs, root
+}
+
+func Test_SftpFs_helpers(t *testing.T) {
+	a := require.New(t)
+	fs, root := newSftpFs(t)
+
+	ufs.WriteLinesP(fs, "/lines.txt", "a", "b")
+	a.Equal([]string{"a", "b"}, ufs.ReadLinesP(fs, "/lines.txt"))
+	a.Equal("a\nb", ufs.ReadTextP(afero.NewOsFs(), filepath.Join(root, "lines.txt")))
+
+	a.Equal(int64(3), ufs.CopyFileP(fs, "/lines.txt", "/copied.txt"))
+	a.Equal("a\nb", ufs.ReadTextP(fs, "/copied.txt"))
+
+	ufs.MkdirP(fs, "/conf")
+	ufs.WriteTextP(fs, "/conf/x.yaml", "x")
+	ufs.WriteTextP(fs, "/conf/y.yaml", "y")
+	ufs.WriteTextP(fs, "/conf/z.txt", "z")
+	a.Equal(map[string]string{"x": "/conf/x.yaml", "y": "/conf/y.yaml"}, ufs.ListSuffixedP(fs, "/conf", ".yaml", false))
+
+	a.True(ufs.DirExistsP(fs, "/conf"))
+	a.False(ufs.FileExistsP(fs, "/missing.txt"))
+}
+
+func Test_SftpFs_dirs(t *testing.T) {
+	a := require.New(t)
+	fs, root := newSftpFs(t)
+
+	a.NoError(fs.MkdirAll("/a/b/c", 0o750))
+	a.NoError(fs.MkdirAll("/a/b", 0o750))
+	fi, err := fs.Stat("/a/b/c")
+	a.NoError(err)
+	a.True(fi.IsDir())
+	a.Equal(os.FileMode(0o750), fi.Mode().Perm())
+
+	ufs.WriteTextP(fs, "/a/b/1.txt", "1")
+	ufs.WriteTextP(fs, "/a/b/2.txt", "2")
+
+	entries, err := afero.ReadDir(fs, "/a/b")
+	a.NoError(err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	a.Equal([]string{"1.txt", "2.txt", "c"}, names)
+
+	dir, err := fs.Open("/a/b")
+	a.NoError(err)
+	page, err := dir.Readdirnames(2)
+	a.NoError(err)
+	a.Len(page, 2)
+	page, err = dir.Readdirnames(2)
+	a.NoError(err)
+	a.Len(page, 1)
+	_, err = dir.Readdirnames(2)
+	a.Error(err)
+	a.NoError(dir.Close())
+
+	a.Error(fs.Remove("/a"))
+	a.NoError(fs.RemoveAll("/a"))
+	a.NoError(fs.RemoveAll("/a"))
+	_, err = os.Stat(filepath.Join(root, "a"))
+	a.True(os.IsNotExist(err))
+}
+
+func Test_SftpFs_files(t *testing.T) {
+	a := require.New(t)
+	fs, _ := newSftpFs(t)
+
+	f, err := fs.OpenFile("/x.txt", os.O_WRONLY|os.O_CREATE, 0o600)
+	a.NoError(err)
+	_, err = f.WriteString("hello")
+	a.NoError(err)
+	a.NoError(f.Close())
+
+	f, err = fs.OpenFile("/x.txt", os.O_WRONLY|os.O_APPEND, 0)
+	a.NoError(err)
+	_, err = f.WriteString(" sftp")
+	a.NoError(err)
+	a.NoError(f.Close())
+
+	fi, err := fs.Stat("/x.txt")
+	a.NoError(err)
+	a.Equal(int64(10), fi.Size())
+	a.Equal(os.FileMode(0o600), fi.Mode().Perm())
+
+	a.NoError(fs.Chmod("/x.txt", 0o640))
+	mtime := time.Date(2022, 10, 1, 8, 30, 0, 0, time.UTC)
+	a.NoError(fs.Chtimes("/x.txt", mtime, mtime))
+	fi = ufs.StatP(fs, "/x.txt", true)
+	a.Equal(os.FileMode(0o640), fi.Mode().Perm())
+	a.True(mtime.Equal(fi.ModTime()))
+
+	ufs.WriteTextP(fs, "/y.txt", "old")
+	a.NoError(fs.Rename("/x.txt", "/y.txt"))
+	a.Equal("hello sftp", ufs.ReadTextP(fs, "/y.txt"))
+
+	_, err = fs.Open("/x.txt")
+	a.True(os.IsNotExist(err))
+	_, err = fs.Stat("/x.txt")
+	a.True(os.IsNotExist(err))
+
+	a.NoError(fs.Remove("/y.txt"))
+	a.False(ufs.FileExistsP(fs, "/y.txt"))
+}
+
+func Test_SftpFs_reconnect(t *testing.T) {
+	a := require.New(t)
+	fs, _ := newSftpFs(t)
+
+	ufs.WriteTextP(fs, "/x.txt", "x")
+	a.NoError(fs.Close())
+
+	// connects again
+	a.Equal("x", ufs.ReadTextP(fs, "/x.txt"))
+}
+
+func Test_SftpFs_WrongPassword(t *testing.T) {
+	a := require.New(t)
+	server := startSftpServer(t, "tester", "secret")
+
+	cred := server.Credentials("wrong")
+	_, err := ufs.NewSftpFs("sftp://"+server.Addr+"/", cred, 5*time.Second)
+	a.Error(err)
+
+	_, err = ufs.NewSftpFs("http://"+server.Addr+"/", cred, 5*time.Second)
+	a.ErrorContains(err, "not a sftp url")
+}
+
+func Test_SftpFs_options(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+	server := startSftpServer(t, "tester", "secret")
+	root := t.TempDir()
+	rootUrl := "sftp://" + server.Addr + filepath.ToSlash(root)
+
+	// the credentials is resolved by the provider
+	provider := ufs.CredentialsProviderFunc(func(u *url.URL) (ufs.Credentials, error) {
+		return server.Credentials("secret"), nil
+	})
+	fs := ufs.NewSftpFsP(rootUrl, nil, 5*time.Second, ufs.WithCredentialsProvider(provider))
+	t.Cleanup(func() { fs.Close() })
+	ufs.WriteTextP(fs, "/x.txt", "x")
+	a.Equal("x", ufs.ReadTextP(afero.NewOsFs(), filepath.Join(root, "x.txt")))
+
+	// the connecting is retried by the policy
+	attempts := 0
+	policy := &ufs.RetryPolicyT{MaxAttempts: 2, Retryable: func(err error) bool {
+		attempts++
+		return true
+	}}
+	_, err := ufs.NewSftpFs(rootUrl, server.Credentials("wrong"), 5*time.Second, ufs.WithRetry(policy))
+	a.Error(err)
+	a.Equal(1, attempts)
+}
+
+func Test_SftpFs_umask(t *testing.T) {
+	a := require.New(t)
+	fs, root := newSftpFs(t)
+
+	// the default umask
+	f, err := fs.Create("/a.txt")
+	a.NoError(err)
+	a.NoError(f.Close())
+	ufs.MkdirP(fs, "/dir")
+	a.NoError(fs.MkdirAll("/x/y", os.ModePerm))
+
+	mode := func(name string) os.FileMode {
+		fi, err := os.Stat(filepath.Join(root, name))
+		a.NoError(err)
+		return fi.Mode().Perm()
+	}
+	a.Equal(os.FileMode(0o644), mode("a.txt"))
+	a.Equal(os.FileMode(0o755), mode("dir"))
+	a.Equal(os.FileMode(0o755), mode("x"))
+	a.Equal(os.FileMode(0o755), mode("x/y"))
+
+	// the specified umask
+	server := startSftpServer(t, "tester", "secret")
+	other := ufs.NewSftpFsP("sftp://"+server.Addr+filepath.ToSlash(root), server.Credentials("secret"), 5*time.Second, ufs.WithUmask(0o077))
+	t.Cleanup(func() { other.Close() })
+	f, err = other.Create("/b.txt")
+	a.NoError(err)
+	a.NoError(f.Close())
+	a.NoError(other.Mkdir("/private", os.ModePerm))
+	a.Equal(os.FileMode(0o600), mode("b.txt"))
+	a.Equal(os.FileMode(0o700), mode("private"))
+
+	// the explicit chmod is not masked
+	a.NoError(other.Chmod("/b.txt", 0o666))
+	a.Equal(os.FileMode(0o666), mode("b.txt"))
+}
@@ -0,0 +1,183 @@
+package test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func newSftpFile(t *testing.T, name string) (ufs.File, string) {
+	server := startSftpServer(t, "tester", "secret")
+	localPath := filepath.Join(t.TempDir(), name)
+
+	cred := server.Credentials("secret")
+	f := ufs.NewFileP(nil, "sftp://"+server.Addr+filepath.ToSlash(localPath), cred, 5*time.Second)
+	return f, localPath
+}
+
+func Test_SftpProtocol_Upload(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newSftpFile(t, "Test_SftpProtocol_Upload.txt")
+
+	n, err := f.Upload(strings.NewReader("hello sftp"))
+	a.NoError(err)
+	a.Equal(int64(len("hello sftp")), n)
+
+	a.Equal("hello sftp", ufs.ReadTextP(afero.NewOsFs(), localPath))
+}
+
+func Test_SftpProtocol_Create(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newSftpFile(t, "Test_SftpProtocol_Create.txt")
+
+	w := f.CreateP()
+	_, err := w.Write([]byte("hello "))
+	a.NoError(err)
+	_, err = w.Write([]byte("world"))
+	a.NoError(err)
+	a.NoError(w.Close())
+
+	a.Equal("hello world", ufs.ReadTextP(afero.NewOsFs(), localPath))
+}
+
+func Test_SftpProtocol_WrongPassword(t *testing.T) {
+	a := require.New(t)
+	server := startSftpServer(t, "tester", "secret")
+
+	cred := server.Credentials("wrong")
+	f := ufs.NewFileP(nil, "sftp://"+server.Addr+"/tmp/x.txt", cred, 5*time.Second)
+
+	_, err := f.Upload(strings.NewReader("x"))
+	a.Error(err)
+}
+
+func Test_SftpProtocol_Stat(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newSftpFile(t, "Test_SftpProtocol_Stat.txt")
+
+	a.False(f.ExistsP())
+	_, err := f.Stat()
+	a.True(os.IsNotExist(err))
+
+	ufs.WriteTextP(afero.NewOsFs(), localPath, "12345")
+	a.True(f.ExistsP())
+
+	fi := f.StatP()
+	a.Equal("Test_SftpProtocol_Stat.txt", fi.Name())
+	a.Equal(int64(5), fi.Size())
+	a.False(fi.IsDir())
+}
+
+func Test_SftpProtocol_List(t *testing.T) {
+	a := require.New(t)
+	server := startSftpServer(t, "tester", "secret")
+
+	dir := t.TempDir()
+	fs := afero.NewOsFs()
+	ufs.WriteTextP(fs, filepath.Join(dir, "a.yaml"), "a")
+	ufs.MkdirP(fs, filepath.Join(dir, "sub"))
+
+	cred := server.Credentials("secret")
+	entries := ufs.ListURLP(nil, "sftp://"+server.Addr+filepath.ToSlash(dir), cred, 5*time.Second)
+	a.Len(entries, 2)
+
+	byName := map[string]os.FileInfo{}
+	for _, entry := range entries {
+		byName[entry.Name()] = entry
+	}
+	a.False(byName["a.yaml"].IsDir())
+	a.Equal(int64(1), byName["a.yaml"].Size())
+	a.True(byName["sub"].IsDir())
+}
+
+func Test_SftpProtocol_Remove(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newSftpFile(t, "Test_SftpProtocol_Remove.txt")
+
+	fs := afero.NewOsFs()
+	ufs.WriteTextP(fs, localPath, "x")
+
+	f.RemoveP()
+	a.False(ufs.FileExistsP(fs, localPath))
+
+	// removing a missing file is not an error
+	f.RemoveP()
+}
+
+func Test_SftpProtocol_Download(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newSftpFile(t, "Test_SftpProtocol_Download.txt")
+
+	ufs.WriteTextP(afero.NewOsFs(), localPath, "hello sftp")
+
+	actual := ufs.DownloadTextP(nil, "", nil, f.Url(), f.Credentials(), 5*time.Second)
+	a.Equal("hello sftp", actual)
+}
+
+func Test_SftpProtocol_DownloadContext_canceled(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newSftpFile(t, "Test_SftpProtocol_DownloadContext_canceled.txt")
+
+	fs := afero.NewOsFs()
+	ufs.WriteTextP(fs, localPath, "hello sftp")
+
+	fallbackDir := t.TempDir()
+	ufs.WriteFallbackFile(fallbackDir, fs, f.Url(), []byte("fallback"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ufs.DownloadTextContext(ctx, nil, fallbackDir, fs, f.Url(), f.Credentials(), 5*time.Second)
+	a.ErrorIs(err, context.Canceled)
+}
+
+func Test_SftpProtocol_Download_tempFile(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newSftpFile(t, "Test_SftpProtocol_Download_tempFile.txt")
+
+	ufs.WriteTextP(afero.NewOsFs(), localPath, "hello sftp")
+
+	streaming := ufs.NewFileP(nil, f.Url(), f.Credentials(), 5*time.Second).DownloadP()
+	a.Equal(filepath.ToSlash(localPath), streaming.Path)
+	txt, err := io.ReadAll(streaming.Blob)
+	a.NoError(err)
+	a.NoError(streaming.Blob.Close())
+	a.Equal("hello sftp", string(txt))
+
+	buffered := ufs.NewFileP(nil, f.Url(), f.Credentials(), 5*time.Second, ufs.WithTempFile()).DownloadP()
+	a.NotEqual(filepath.ToSlash(localPath), buffered.Path)
+	txt, err = io.ReadAll(buffered.Blob)
+	a.NoError(err)
+	a.NoError(buffered.Blob.Close())
+	a.Equal("hello sftp", string(txt))
+}
+
+func Test_SftpProtocol_OpenRandom(t *testing.T) {
+	a := require.New(t)
+	f, localPath := newSftpFile(t, "Test_SftpProtocol_OpenRandom.bin")
+
+	ufs.WriteTextP(afero.NewOsFs(), localPath, "0123456789")
+
+	r := f.OpenRandomP()
+	defer r.Close()
+	a.Equal(int64(10), r.Size())
+
+	p := make([]byte, 3)
+	_, err := r.ReadAt(p, 5)
+	a.NoError(err)
+	a.Equal("567", string(p))
+
+	_, err = r.Seek(8, io.SeekStart)
+	a.NoError(err)
+	rest, err := io.ReadAll(r)
+	a.NoError(err)
+	a.Equal("89", string(rest))
+}
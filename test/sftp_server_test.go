@@ -0,0 +1,117 @@
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/qiangyt/go-ufs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+type sftpServerT struct {
+	Addr    string
+	HostKey ssh.PublicKey
+	// the SHA256 fingerprint of the host key
+	Fingerprint string
+	User        string
+}
+
+type sftpServer = *sftpServerT
+
+// Credentials pins the host key fingerprint
+func (me sftpServer) Credentials(password string) ufs.Credentials {
+	return &ufs.CredentialsT{User: me.User, Password: password, HostKeyFingerprint: me.Fingerprint}
+}
+
+// startSftpServer starts an in-process sftp server which serves the local filesystem.
+// The server accepts only the given user/password.
+func startSftpServer(t *testing.T, user string, password string) sftpServer {
+	return startSftpServerWith(t, user, &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %q", c.User())
+		},
+	})
+}
+
+// startSftpServerWith starts an in-process sftp server with the auth callbacks of the config,
+// and a new ed25519 host key
+func startSftpServerWith(t *testing.T, user string, config *ssh.ServerConfig) sftpServer {
+	a := require.New(t)
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	a.NoError(err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	a.NoError(err)
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NoError(err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSftp(conn, config)
+		}
+	}()
+
+	return &sftpServerT{
+		Addr:        listener.Addr().String(),
+		HostKey:     hostSigner.PublicKey(),
+		Fingerprint: ssh.FingerprintSHA256(hostSigner.PublicKey()),
+		User:        user,
+	}
+}
+
+func serveSftp(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for req := range requests {
+				isSftp := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(isSftp, nil)
+				if !isSftp {
+					continue
+				}
+
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					channel.Close()
+					return
+				}
+				go func() {
+					server.Serve()
+					server.Close()
+				}()
+			}
+		}()
+	}
+}
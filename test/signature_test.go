@@ -0,0 +1,240 @@
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiangyt/go-ufs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignKeyT signs the content as minisign does
+type minisignKeyT struct {
+	id         []byte
+	publicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+}
+
+type minisignKey = *minisignKeyT
+
+func newMinisignKey(t *testing.T) minisignKey {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	id := make([]byte, 8)
+	_, err = rand.Read(id)
+	require.NoError(t, err)
+	return &minisignKeyT{id: id, publicKey: publicKey, privateKey: privateKey}
+}
+
+func (me minisignKey) PublicKey() string {
+	return "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), me.id...), me.publicKey...)) + "\n"
+}
+
+func (me minisignKey) Sign(content string, hashed bool, trustedComment string) string {
+	algorithm, message := "Ed", []byte(content)
+	if hashed {
+		digest := blake2b.Sum512(message)
+		algorithm, message = "ED", digest[:]
+	}
+	sig := ed25519.Sign(me.privateKey, message)
+	globalSig := ed25519.Sign(me.privateKey, append(append([]byte{}, sig...), trustedComment...))
+
+	return "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte(algorithm), me.id...), sig...)) + "\n" +
+		"trusted comment: " + trustedComment + "\n" +
+		base64.StdEncoding.EncodeToString(globalSig) + "\n"
+}
+
+func Test_ParseSignatureKey(t *testing.T) {
+	a := require.New(t)
+	key := newMinisignKey(t)
+
+	parsed := ufs.ParseSignatureKeyP(key.PublicKey())
+	a.Equal(key.id, parsed.Id)
+	a.Equal(key.publicKey, parsed.Key)
+
+	parsed = ufs.ParseSignatureKeyP(strings.Split(key.PublicKey(), "\n")[1])
+	a.Equal(key.id, parsed.Id)
+
+	parsed = ufs.ParseSignatureKeyP(hex.EncodeToString(key.publicKey))
+	a.Nil(parsed.Id)
+	a.Equal(key.publicKey, parsed.Key)
+
+	parsed = ufs.ParseSignatureKeyP(base64.StdEncoding.EncodeToString(key.publicKey))
+	a.Equal(key.publicKey, parsed.Key)
+
+	_, err := ufs.ParseSignatureKey("hello")
+	a.ErrorContains(err, "not a minisign or ed25519 public key")
+}
+
+func Test_WithTrustedKeys_minisign(t *testing.T) {
+	a := require.New(t)
+	key := newMinisignKey(t)
+	other := newMinisignKey(t)
+	fs := afero.NewMemMapFs()
+
+	// the afero blob removes the file once closed
+	download := func(content string, sig string, keys ...ufs.SignatureKey) (string, error) {
+		ufs.WriteTextP(fs, "/dist/a.yaml", content)
+		if sig != "" {
+			ufs.WriteTextP(fs, "/dist/a.yaml.sig", sig)
+		}
+		return ufs.DownloadText(nil, "", fs, "/dist/a.yaml", nil, 0, ufs.WithTrustedKeys(keys...))
+	}
+	trusted := ufs.ParseSignatureKeyP(key.PublicKey())
+
+	for _, hashed := range []bool{true, false} {
+		actual, err := download("k: v", key.Sign("k: v", hashed, "timestamp:1"), trusted)
+		a.NoError(err)
+		a.Equal("k: v", actual)
+	}
+
+	// either of the trusted keys
+	actual, err := download("k: v", key.Sign("k: v", true, "timestamp:1"), ufs.ParseSignatureKeyP(other.PublicKey()), trusted)
+	a.NoError(err)
+	a.Equal("k: v", actual)
+
+	_, err = download("k: tampered", key.Sign("k: v", true, "timestamp:1"), trusted)
+	var signatureErr *ufs.SignatureError
+	a.True(errors.As(err, &signatureErr))
+	a.Equal("file:///dist/a.yaml", signatureErr.Url)
+	a.Contains(signatureErr.Reason, "not signed by any trusted key")
+
+	_, err = download("k: v", other.Sign("k: v", true, "timestamp:1"), trusted)
+	a.ErrorContains(err, "not signed by any trusted key, key id ")
+
+	sig := strings.Replace(key.Sign("k: v", true, "timestamp:1"), "timestamp:1", "timestamp:2", 1)
+	_, err = download("k: v", sig, trusted)
+	a.ErrorContains(err, "bad signature of the trusted comment")
+
+	_, err = download("k: v", "untrusted comment: x\nhello\n", trusted)
+	a.ErrorContains(err, "malformed minisign signature")
+
+	fs.Remove("/dist/a.yaml.sig")
+	_, err = download("k: v", "", trusted)
+	a.ErrorContains(err, "unsigned")
+
+	// not verified without trusted keys
+	actual, err = download("k: v", "")
+	a.NoError(err)
+	a.Equal("k: v", actual)
+}
+
+func Test_WithTrustedKeys_ed25519(t *testing.T) {
+	a := require.New(t)
+	key := newMinisignKey(t)
+	fs := afero.NewMemMapFs()
+	trusted := ufs.ParseSignatureKeyP(hex.EncodeToString(key.publicKey))
+
+	sig := ed25519.Sign(key.privateKey, []byte("hello"))
+	for _, encoded := range []string{string(sig), base64.StdEncoding.EncodeToString(sig), hex.EncodeToString(sig) + "\n"} {
+		ufs.WriteTextP(fs, "/a.txt", "hello")
+		ufs.WriteTextP(fs, "/a.txt.sig", encoded)
+
+		f := ufs.NewFileP(fs, "/a.txt", nil, 0, ufs.WithTrustedKeys(trusted))
+		c, err := f.Download()
+		a.NoError(err)
+		actual, err := io.ReadAll(c.Blob)
+		a.NoError(err)
+		a.Equal("hello", string(actual))
+	}
+
+	ufs.WriteTextP(fs, "/a.txt", "bye")
+	_, err := ufs.NewFileP(fs, "/a.txt", nil, 0, ufs.WithTrustedKeys(trusted)).Download()
+	a.ErrorContains(err, "not signed by any trusted key")
+
+	ufs.WriteTextP(fs, "/a.txt", "hello")
+	ufs.WriteTextP(fs, "/a.txt.sig", "abc")
+	_, err = ufs.NewFileP(fs, "/a.txt", nil, 0, ufs.WithTrustedKeys(trusted)).Download()
+	a.ErrorContains(err, "malformed signature")
+}
+
+func Test_WithTrustedKeys_fallback(t *testing.T) {
+	a := require.New(t)
+	isolateCredentials(t)
+	key := newMinisignKey(t)
+
+	content, sig, down, sigDown := "k: v", key.Sign("k: v", true, "timestamp:1"), false, false
+	sigRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ufs.SignatureSuffix) {
+			sigRequests++
+		}
+		switch {
+		case down, sigDown && strings.HasSuffix(r.URL.Path, ufs.SignatureSuffix):
+			w.WriteHeader(http.StatusNotImplemented)
+		case r.URL.Path == "/b.yaml":
+			io.WriteString(w, "k: unsigned")
+		case r.URL.Path == "/a.yaml":
+			io.WriteString(w, content)
+		case r.URL.Path == "/a.yaml.sig" && sig != "":
+			io.WriteString(w, sig)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+	fallbackDir := "/fallback"
+	url := server.URL + "/a.yaml"
+	trusted := ufs.WithTrustedKeys(ufs.ParseSignatureKeyP(key.PublicKey()))
+
+	a.Equal("k: v", ufs.DownloadTextP(nil, fallbackDir, fs, url, nil, 0, trusted))
+
+	// the signature which the download is verified against is saved, without reading it again
+	a.Equal(1, sigRequests)
+	_, savedSig, err := ufs.ReadFallbackFile(fallbackDir, fs, url+ufs.SignatureSuffix)
+	a.NoError(err)
+	a.Equal(sig, string(savedSig))
+
+	// the badly signed or unsigned content neither replaces the fallback file, nor falls back to it
+	content = "k: tampered"
+	_, err = ufs.DownloadText(nil, fallbackDir, fs, url, nil, 0, trusted)
+	a.ErrorContains(err, "not signed by any trusted key")
+
+	sig = ""
+	_, err = ufs.DownloadText(nil, fallbackDir, fs, url, nil, 0, trusted)
+	a.ErrorContains(err, "unsigned")
+
+	_, fallback, err := ufs.ReadFallbackFile(fallbackDir, fs, url)
+	a.NoError(err)
+	a.Equal("k: v", string(fallback))
+
+	// the signature which fails to be read is a SignatureError, so not falls back either
+	sig, content, sigDown = key.Sign("k: v", true, "timestamp:1"), "k: v", true
+	_, err = ufs.DownloadText(nil, fallbackDir, fs, url, nil, 0, trusted)
+	var signatureErr *ufs.SignatureError
+	a.ErrorAs(err, &signatureErr)
+	a.ErrorContains(err, "read signature")
+	sigDown = false
+
+	// falls back to the verified one if the server is down
+	down = true
+	a.Equal("k: v", ufs.DownloadTextP(nil, fallbackDir, fs, url, nil, 0, trusted))
+
+	// the fallback file of the unsigned download is not used with the trusted keys
+	down = false
+	unsignedUrl := server.URL + "/b.yaml"
+	a.Equal("k: unsigned", ufs.DownloadTextP(nil, fallbackDir, fs, unsignedUrl, nil, 0))
+	down = true
+	a.Equal("k: unsigned", ufs.DownloadTextP(nil, fallbackDir, fs, unsignedUrl, nil, 0))
+	_, err = ufs.DownloadText(nil, fallbackDir, fs, unsignedUrl, nil, 0, trusted)
+	a.ErrorContains(err, "501 Not Implemented")
+
+	// nor the tampered fallback file
+	ufs.WriteTextP(fs, ufs.FallbackFilePath(fallbackDir, url), "k: tampered")
+	_, err = ufs.DownloadText(nil, fallbackDir, fs, url, nil, 0, trusted)
+	a.ErrorContains(err, "501 Not Implemented")
+}